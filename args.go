@@ -6,26 +6,108 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// Subcommands; invoking ghc with flags only is an alias for run.
+const (
+	cmdRun     = "run"
+	cmdStatus  = "status"
+	cmdVerify  = "verify"
+	cmdPrune   = "prune"
+	cmdDB      = "db"
+	cmdHistory = "history"
+)
+
+var subcommands = []string{cmdRun, cmdStatus, cmdVerify, cmdPrune, cmdDB, cmdHistory}
+
 func showUsage() {
-	fmt.Fprintf(os.Stderr, `Usage: %[1]s [OPTIONS] [FILES...]
+	fmt.Fprintf(os.Stderr, `Usage: %[1]s [COMMAND] [OPTIONS] [FILES...]
 
 GoHashCheckMe - Run commands on files and store their hashes based on exit codes.
 Avoids expensive operations on unchanged files when using audit mode.
 
+COMMANDS:
+  run                          Run the check command on files (default when no command is given)
+  status                       Classify files as new, changed, unchanged or missing without running commands
+                               (same as run --dry-run)
+  verify                       Check every hashes file entry against the file on disk, like sha256sum -c
+  prune                        Drop hashes file entries for files that no longer exist (and with
+                               --prune-outside, for files outside PATHS)
+  db ACTION [ARGS]             Inspect and edit the hashes file:
+                                 list               Print all entries
+                                 get FILE...        Print the entries for FILEs
+                                 set FILE [HASH]    Store HASH (default: current hash) for FILE
+                                 rm FILE...         Remove the entries for FILEs
+                                 import MANIFEST... Add the entries of MANIFESTs (format: --format, default auto)
+                                 export [OUTPUT]    Write all entries to OUTPUT or stdout (format: --format,
+                                                    default coreutils)
+                                 compact            Rewrite the hashes file sorted, one entry per file
+                                 convert [OUTPUT]   Rewrite the hashes file, or write it to OUTPUT, in the
+                                                    binary (default) or jsonl format (--format)
+  history ACTION               Query the run history recorded with --history, over the last --last runs:
+                                 runs               Print the runs: time, arguments, git commit and statistics
+                                 failing            Files that failed, most failures first
+                                 slower             Files whose check took longer in their latest run than before
+                                 flaky              Files whose outcome changed while their content didn't
+
 OPTIONS:
   -c, --check-command COMMAND    Command to run on each file
   -a, --audit                   Enable audit mode (only run commands on changed/unknown files)
-  -f, --hashes-file FILE        File with known hashes for audit mode (JSONL format)
-  -u, --update                  Update hashes file with new successful file hashes
+  -f, --hashes-file FILE        File with known hashes for audit mode (JSONL or binary, or a coreutils,
+                                BSD or sidecar manifest, detected automatically)
+  -u, --update                  Update hashes file with file hashes and check results
   --success-exit-codes CODES   Comma-separated success exit codes to include in output
   --error-exit-codes CODES     Comma-separated error exit codes to include in output
   -w, --workers N               Number of concurrent workers (default: CPU count)
   -p, --progress                Show progress bar
   -q, --quiet                   Quiet mode (no error output, suppresses stdout if -f given)
+  -n, --dry-run                 Classify files without running commands; exit 1 if any are new or changed
+  --format FORMAT              Output format: jsonl (default); for run also text, table, template
+                               (see --template), github or gitlab-codequality (see --diagnostic-pattern);
+                               text (default) or jsonl for verify;
+                               manifest format for db import/export: coreutils, bsd, sidecar, jsonl or auto
+                               binary or jsonl for db convert
+  --template TEXT              Go text/template rendered for each result, e.g. '{{.Filename}} {{.ExitCode}}'
+  --ignore-missing             verify: don't fail or report status for missing files
+  --strict                     verify: exit non-zero for improperly formatted hashes file entries
+  --rerun-failed               Audit mode: re-run unchanged files whose cached result is a failure
+  --max-age DURATION           Audit mode: re-run files checked longer ago than DURATION (e.g. 12h, 30d)
+  --reverify-fraction F        Audit mode: re-run a random fraction F (0-1) of unchanged files
+  --fail-on POLICY             Exit non-zero on: failure (failed checks or errors, default), error
+                               (only files that could not be hashed or checked) or none
+  --fail-fast                  Stop at the first outcome failing the run (see --fail-on): no new
+                               files are started and running checks are killed
+  --stats FILE                 Write the statistics of the run to FILE as JSON, instead of ending the
+                               JSONL output with a summary record
+  --junit FILE                 Write a JUnit XML report of the run to FILE
+  --sarif FILE                 Write a SARIF log of the failed checks to FILE
+  --html-report FILE           Write a self-contained HTML page of the run to FILE, with charts and a
+                               sortable, filterable table of the files
+  --output FILE                Also write the JSONL results and summary record to FILE, gzip-compressed
+                               if it ends in .gz, whatever the --format on stdout
+  --output-append              Append to the --output file instead of replacing it
+  --output-max-size SIZE       With --output-append, rotate the --output file once it reaches SIZE
+                               bytes (K, M or G suffixes), keeping --output-keep rotated files
+  --output-keep N              Number of rotated --output files to keep (default: 5)
+  --diagnostic-pattern REGEX   Find problems in the output of failed checks for reports, with the
+                               named groups file, line, col and msg (default: file:line:col: msg)
+  --history                    Record the run in the run history kept next to the hashes file
+  --last N                     history: query the last N runs (default 10)
+  --journal                    Update mode: append results to the hashes file as they finish
+  --trust-content              Skip files whose content already passed the same check under any path
+  --prune                      Drop hashes file entries for files that no longer exist
+  --prune-outside PATHS        Drop hashes file entries for files outside the comma-separated PATHS
+  --include PATTERNS           Comma-separated glob patterns of files to process
+  --exclude PATTERNS           Comma-separated glob patterns of files to skip
+  --timeout DURATION           Stop commands running longer than DURATION (e.g. 30s, exit code 124)
+  --rule-mode MODE             Run the "first" matching rule (default) or "all" matching rules
+  --unmatched MODE             Files matching no rule: "default" runs -c (default), "skip" ignores them
+  --config FILE                Project config file (default: nearest .ghc.yaml, .ghc.yml or .ghc.json)
+  --profile NAME               Apply the named profile from the config file
   -h, --help                    Show this help message
 
 EXAMPLES:
@@ -50,19 +132,107 @@ EXAMPLES:
   # Use $FILE placeholder in command for more control
   %[1]s -c "diff $FILE expected.txt" test_files/
 
+  # Show which files would be checked, without running anything
+  %[1]s status -f hashes.jsonl *.txt
+
+  # Verify the hashes file like a SHA256SUMS manifest
+  %[1]s verify -f hashes.jsonl
+
+  # Verify as JSONL, ignoring files that were deleted
+  %[1]s verify --format jsonl --ignore-missing -f hashes.jsonl
+
+  # Seed audit mode from an existing SHA256SUMS, then convert it to the native format
+  %[1]s -a -f SHA256SUMS -c "mycheck" *.txt
+  %[1]s db import -f hashes.jsonl SHA256SUMS
+
+  # Export the hashes file as a BSD-style manifest
+  %[1]s db export --format bsd -f hashes.jsonl CHECKSUMS
+
+  # Fail a CI job only if files could not be hashed or checked, not on failed checks
+  %[1]s -a -f hashes.jsonl -c "mycheck" --fail-on error src/
+
+  # Pre-commit hook: stop at the first failed check
+  git diff --cached --name-only | %[1]s -a -u -f hashes.jsonl -c "mycheck" --fail-fast
+
+  # Keep the statistics of a run apart from its results
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --stats stats.json src/
+
+  # Write a JUnit report for the CI server
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --junit report.xml src/
+
+  # Upload failed checks to code scanning, locating problems in the check output
+  %[1]s -c "mylint" --sarif results.sarif --diagnostic-pattern '^(?P<line>\d+): (?P<msg>.*)$' src/
+
+  # Share the results of a nightly run as a web page
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --html-report report.html src/
+
+  # Watch PASS/FAIL lines while keeping a compressed JSONL log of every run
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --format text --output results.jsonl.gz --output-append src/
+
+  # Show PASS/FAIL lines while working locally, or a table once the run is done
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --format text src/
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --format table src/
+
+  # Print the files whose check failed
+  %[1]s -c "mycheck" --template '{{if .Failed}}{{.Filename}}{{end}}' src/
+
+  # Annotate failed checks in a GitHub Actions job, or report them to GitLab Code Quality
+  %[1]s -a -u -f hashes.jsonl -c "mylint" --format github src/
+  %[1]s -a -u -f hashes.jsonl -c "mylint" --format gitlab-codequality src/ > gl-code-quality.json
+
+  # Keep a history of runs, then list the files that failed most in the last 20
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --history src/
+  %[1]s history failing --last 20 -f hashes.jsonl
+
+  # Append results to the hashes file as they finish, then tidy it up
+  %[1]s -a -u --journal -f hashes.jsonl -c "mycheck" *.txt
+  %[1]s db compact -f hashes.jsonl
+
+  # Convert a large hashes file to the indexed binary format, and back
+  %[1]s db convert -f hashes.jsonl
+  %[1]s db convert --format jsonl -f hashes.jsonl
+
+  # Check all known files, forgetting deleted ones and anything outside src/
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --prune --prune-outside src
+
+  # Check identical vendored copies only once
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --trust-content vendor/
+
+  # Re-check files verified over 30 days ago, plus a random 5%% of the rest
+  %[1]s -a -u -f hashes.jsonl -c "mycheck" --max-age 30d --reverify-fraction 0.05 *.txt
+
+  # Use the settings from .ghc.yaml with its "ci" profile, overriding the workers
+  %[1]s --profile ci -w 4
+
+EXIT STATUS:
+  0    All checks passed (or --fail-on none)
+  1    Some checks failed: exit code not 0, or not in --success-exit-codes if given
+  2    Usage error
+  3    Some files could not be hashed, or their command could not be run
+  130  Interrupted by SIGINT or SIGTERM
+  status exits 1 if any file needs checking, verify on any mismatch and db get
+  for a missing entry
+
 MODES:
-  - Normal mode: Run command on all given files
+  - Normal mode: Run command on all given files (same as the run command)
   - Audit mode (-a): Only run command on changed/unknown files (requires -f)
-  - Update mode (-u): Write successful file hashes to .new file and merge into hashes file
+  - Update mode (-u): Write file hashes and results to .new file and merge into hashes file
 
 NOTES:
   - Files can be specified as arguments or read from stdin
   - Output is to stdout in JSONL format with filename, hash, exit_code, and audit info
   - Quiet mode (-q) with hashes file (-f) suppresses stdout output
   - Use $FILE in command to specify exact placement of filename
-  - Update mode creates a .new file with successful hashes and merges into existing file
+  - Update mode creates a .new file with results and merges into existing file; failures are
+    cached with their exit code and output and replayed for unchanged files in audit mode
   - Only one hash per filename is maintained (new hashes overwrite existing ones)
+  - Ctrl-C/SIGTERM stops starting new files and saves finished results (again: stop running checks);
+    the run exits with status 130
   - Exit code filtering: specify --success-exit-codes and/or --error-exit-codes to filter results
+  - Settings from the config file apply first; flags given on the command line override them
+  - Rules from the config file map glob patterns to commands, exit-code filters and timeouts
+  - verify prints "FILE: OK|FAILED|MISSING" lines and exits 1 on any mismatch; -q prints nothing
+  - Patterns without a slash match the base name ("*.go"); "**" matches any number of directories
 
 `, os.Args[0])
 }
@@ -70,16 +240,46 @@ NOTES:
 func parseFlags() Config {
 	var cfg Config
 	var successCodeStr, errorCodeStr string
+	var includeStr, excludeStr, timeoutStr, pruneOutsideStr, maxAgeStr, diagnosticPatternStr, templateStr, outputMaxSizeStr string
+	var configFile, profile string
 	var showHelp bool
 
+	// A leading subcommand selects the operation; db also takes an action
+	args := os.Args[1:]
+	cfg.subcommand = cmdRun
+	if len(args) > 0 && slices.Contains(subcommands, args[0]) {
+		cfg.subcommand = args[0]
+		args = args[1:]
+	}
+	if cfg.subcommand == cmdDB {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			fmt.Fprintln(os.Stderr, "Error: db requires an action (list, get, set, rm, import, export, compact or convert)")
+			fmt.Fprintln(os.Stderr)
+			showUsage()
+			os.Exit(exitUsage)
+		}
+		cfg.dbAction = args[0]
+		args = args[1:]
+	}
+	if cfg.subcommand == cmdHistory {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			fmt.Fprintln(os.Stderr, "Error: history requires an action (runs, failing, slower or flaky)")
+			fmt.Fprintln(os.Stderr)
+			showUsage()
+			os.Exit(exitUsage)
+		}
+		cfg.historyAction = args[0]
+		args = args[1:]
+	}
+
 	flag.StringVar(&cfg.command, "c", "", "Command to run on each file")
 	flag.StringVar(&cfg.command, "check-command", "", "Command to run on each file")
 	flag.BoolVar(&cfg.audit, "a", false, "Enable audit mode (only run commands on changed/unknown files)")
 	flag.BoolVar(&cfg.audit, "audit", false, "Enable audit mode (only run commands on changed/unknown files)")
 	flag.StringVar(&cfg.hashesFile, "f", "", "File with known hashes for audit mode (JSONL format)")
 	flag.StringVar(&cfg.hashesFile, "hashes-file", "", "File with known hashes for audit mode (JSONL format)")
-	flag.BoolVar(&cfg.update, "u", false, "Update hashes file with new successful file hashes")
-	flag.BoolVar(&cfg.update, "update", false, "Update hashes file with new successful file hashes")
+	flag.BoolVar(&cfg.update, "u", false, "Update hashes file with file hashes and check results")
+	flag.BoolVar(&cfg.update, "update", false, "Update hashes file with file hashes and check results")
 	flag.StringVar(&successCodeStr, "success-exit-codes", "", "Comma-separated success exit codes to include in output")
 	flag.StringVar(&errorCodeStr, "error-exit-codes", "", "Comma-separated error exit codes to include in output")
 	flag.IntVar(&cfg.workers, "w", 0, "Number of concurrent workers (default: CPU count)")
@@ -88,44 +288,270 @@ func parseFlags() Config {
 	flag.BoolVar(&cfg.showProgress, "progress", false, "Show progress bar")
 	flag.BoolVar(&cfg.quiet, "q", false, "Quiet mode (no error output)")
 	flag.BoolVar(&cfg.quiet, "quiet", false, "Quiet mode (no error output)")
+	flag.BoolVar(&cfg.dryRun, "n", false, "Classify files without running commands")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "Classify files without running commands")
+	flag.StringVar(&cfg.format, "format", "", "Output format")
+	flag.StringVar(&templateStr, "template", "", "Go template rendered for each result")
+	flag.BoolVar(&cfg.ignoreMissing, "ignore-missing", false, "verify: don't fail or report status for missing files")
+	flag.BoolVar(&cfg.strict, "strict", false, "verify: exit non-zero for improperly formatted entries")
+	flag.BoolVar(&cfg.trustContent, "trust-content", false, "Skip files whose content already passed the same check under any path")
+	flag.StringVar(&cfg.failOn, "fail-on", "", "Exit non-zero on failure, error or none")
+	flag.BoolVar(&cfg.failFast, "fail-fast", false, "Stop at the first outcome failing the run")
+	flag.StringVar(&cfg.junitFile, "junit", "", "Write a JUnit XML report of the run to this file")
+	flag.StringVar(&cfg.statsFile, "stats", "", "Write the statistics of the run to this file")
+	flag.StringVar(&cfg.sarifFile, "sarif", "", "Write a SARIF log of the failed checks to this file")
+	flag.StringVar(&cfg.htmlReportFile, "html-report", "", "Write a self-contained HTML report of the run to this file")
+	flag.StringVar(&cfg.outputFile, "output", "", "Also write the JSONL results to this file")
+	flag.BoolVar(&cfg.outputAppend, "output-append", false, "Append to the --output file instead of replacing it")
+	flag.StringVar(&outputMaxSizeStr, "output-max-size", "", "Rotate the --output file once it reaches this size")
+	flag.IntVar(&cfg.outputKeep, "output-keep", 5, "Number of rotated --output files to keep")
+	flag.StringVar(&diagnosticPatternStr, "diagnostic-pattern", "", "Regular expression matching problems in check output")
+	flag.BoolVar(&cfg.history, "history", false, "Record the run in the run history kept with the hashes file")
+	flag.IntVar(&cfg.historyRuns, "last", 10, "history: query the last N runs")
+	flag.BoolVar(&cfg.journal, "journal", false, "Update mode: append results to the hashes file as they finish")
+	flag.BoolVar(&cfg.rerunFailed, "rerun-failed", false, "Audit mode: re-run unchanged files whose cached result is a failure")
+	flag.StringVar(&maxAgeStr, "max-age", "", "Audit mode: re-run files checked longer ago than this duration")
+	flag.Float64Var(&cfg.reverifyFraction, "reverify-fraction", 0, "Audit mode: re-run a random fraction of unchanged files")
+	flag.BoolVar(&cfg.prune, "prune", false, "Drop hashes file entries for files that no longer exist")
+	flag.StringVar(&pruneOutsideStr, "prune-outside", "", "Drop hashes file entries for files outside these paths")
+	flag.StringVar(&includeStr, "include", "", "Comma-separated glob patterns of files to process")
+	flag.StringVar(&excludeStr, "exclude", "", "Comma-separated glob patterns of files to skip")
+	flag.StringVar(&timeoutStr, "timeout", "", "Stop commands running longer than this duration")
+	flag.StringVar(&cfg.ruleMode, "rule-mode", "", "Run the first matching rule or all matching rules")
+	flag.StringVar(&cfg.unmatched, "unmatched", "", "Handling of files matching no rule (default or skip)")
+	flag.StringVar(&configFile, "config", "", "Project config file")
+	flag.StringVar(&profile, "profile", "", "Apply the named profile from the config file")
 	flag.BoolVar(&showHelp, "h", false, "Show help message")
 	flag.BoolVar(&showHelp, "help", false, "Show help message")
 
 	flag.Usage = showUsage
-	flag.Parse()
+	_ = flag.CommandLine.Parse(args) // exits on error
 
 	if showHelp {
 		showUsage()
 		os.Exit(0)
 	}
 
-	if cfg.command == "" && !cfg.audit {
+	cfg.successCodes = parseExitCodes(successCodeStr)
+	cfg.errorCodes = parseExitCodes(errorCodeStr)
+	cfg.include = parsePatterns(includeStr)
+	cfg.exclude = parsePatterns(excludeStr)
+	cfg.pruneOutside = parsePatterns(pruneOutsideStr)
+
+	if timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid timeout '%s': %v\n", timeoutStr, err)
+			os.Exit(exitUsage)
+		}
+		cfg.timeout = timeout
+	}
+	if maxAgeStr != "" {
+		maxAge, err := parseAge(maxAgeStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid max age '%s': %v\n", maxAgeStr, err)
+			os.Exit(exitUsage)
+		}
+		cfg.maxAge = maxAge
+	}
+	if outputMaxSizeStr != "" {
+		size, err := parseSize(outputMaxSizeStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid output max size: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		cfg.outputMaxSize = size
+	}
+	if cfg.outputKeep < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid output keep %d (expected 0 or more)\n", cfg.outputKeep)
+		os.Exit(exitUsage)
+	}
+	if diagnosticPatternStr != "" {
+		pattern, err := parseDiagnosticPattern(diagnosticPatternStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid diagnostic pattern '%s': %v\n", diagnosticPatternStr, err)
+			os.Exit(exitUsage)
+		}
+		cfg.diagnosticPattern = pattern
+	}
+	if cfg.reverifyFraction < 0 || cfg.reverifyFraction > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid reverify fraction %g (expected 0 to 1)\n", cfg.reverifyFraction)
+		os.Exit(exitUsage)
+	}
+
+	// Settings from the project config file apply unless overridden by a flag
+	if configFile == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			configFile = findConfigFile(cwd)
+		}
+	}
+	if configFile == "" && profile != "" {
+		fmt.Fprintln(os.Stderr, "Error: --profile requires a config file")
+		os.Exit(exitUsage)
+	}
+	if configFile != "" {
+		fc, err := loadConfigFile(configFile, profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config file: %v\n", err)
+			os.Exit(exitUsage)
+		}
+
+		setFlags := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+		if err := applyFileConfig(&cfg, fc, setFlags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config file %s: %v\n", configFile, err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	if cfg.subcommand == cmdStatus {
+		cfg.dryRun = true
+	}
+
+	if cfg.subcommand == cmdRun && !cfg.dryRun && cfg.command == "" && len(cfg.rules) == 0 && !cfg.audit {
 		fmt.Fprintln(os.Stderr, "Error: Either command (-c) or audit mode (--audit) is required")
 		fmt.Fprintln(os.Stderr)
 		showUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	if cfg.subcommand != cmdRun && cfg.hashesFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: The %s command requires -f (hashes file) to be specified\n", cfg.subcommand)
+		fmt.Fprintln(os.Stderr)
+		showUsage()
+		os.Exit(exitUsage)
 	}
 
 	if cfg.audit && cfg.hashesFile == "" {
 		fmt.Fprintln(os.Stderr, "Error: Audit mode requires -f (hashes file) to be specified")
 		fmt.Fprintln(os.Stderr)
 		showUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	if cfg.update && cfg.hashesFile == "" {
 		fmt.Fprintln(os.Stderr, "Error: Update mode requires -f (hashes file) to be specified")
 		fmt.Fprintln(os.Stderr)
 		showUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	if cfg.prune || len(cfg.pruneOutside) > 0 {
+		if cfg.subcommand != cmdRun && cfg.subcommand != cmdStatus && cfg.subcommand != cmdPrune {
+			fmt.Fprintf(os.Stderr, "Error: --prune and --prune-outside don't apply to the %s command\n", cfg.subcommand)
+			os.Exit(exitUsage)
+		}
+		if cfg.hashesFile == "" {
+			fmt.Fprintln(os.Stderr, "Error: --prune and --prune-outside require -f (hashes file) to be specified")
+			fmt.Fprintln(os.Stderr)
+			showUsage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	if (cfg.junitFile != "" || cfg.sarifFile != "" || cfg.htmlReportFile != "" || cfg.statsFile != "") && (cfg.subcommand != cmdRun || cfg.dryRun) {
+		fmt.Fprintln(os.Stderr, "Error: --junit, --sarif, --html-report and --stats only apply to runs that check files")
+		os.Exit(exitUsage)
+	}
+	if cfg.history && cfg.subcommand == cmdRun && cfg.hashesFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --history requires -f (hashes file) to be specified")
+		os.Exit(exitUsage)
+	}
+	if cfg.historyRuns <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid number of runs %d for --last\n", cfg.historyRuns)
+		os.Exit(exitUsage)
+	}
+	if cfg.outputFile != "" && cfg.subcommand != cmdRun && cfg.subcommand != cmdStatus {
+		fmt.Fprintf(os.Stderr, "Error: --output doesn't apply to the %s command\n", cfg.subcommand)
+		os.Exit(exitUsage)
+	}
+	if cfg.outputAppend && cfg.outputFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --output-append requires --output")
+		os.Exit(exitUsage)
+	}
+	if cfg.outputMaxSize > 0 && !cfg.outputAppend {
+		fmt.Fprintln(os.Stderr, "Error: --output-max-size requires --output-append")
+		os.Exit(exitUsage)
+	}
+	if cfg.diagnosticPattern == nil {
+		cfg.diagnosticPattern, _ = parseDiagnosticPattern(defaultDiagnosticPattern)
+	}
+
+	if cfg.ruleMode == "" {
+		cfg.ruleMode = ruleModeFirst
+	}
+	if cfg.ruleMode != ruleModeFirst && cfg.ruleMode != ruleModeAll {
+		fmt.Fprintf(os.Stderr, "Error: invalid rule mode '%s' (expected first or all)\n", cfg.ruleMode)
+		os.Exit(exitUsage)
+	}
+
+	if cfg.unmatched == "" {
+		cfg.unmatched = unmatchedDefault
+	}
+	if cfg.unmatched != unmatchedDefault && cfg.unmatched != unmatchedSkip {
+		fmt.Fprintf(os.Stderr, "Error: invalid unmatched mode '%s' (expected default or skip)\n", cfg.unmatched)
+		os.Exit(exitUsage)
+	}
+
+	if cfg.failOn == "" {
+		cfg.failOn = failOnFailure
+	}
+	if !slices.Contains(failOnPolicies, cfg.failOn) {
+		fmt.Fprintf(os.Stderr, "Error: invalid fail-on policy '%s' (expected %s)\n", cfg.failOn, strings.Join(failOnPolicies, ", "))
+		os.Exit(exitUsage)
 	}
 
 	if cfg.workers <= 0 {
 		cfg.workers = runtime.NumCPU()
 	}
 
-	cfg.successCodes = parseExitCodes(successCodeStr)
-	cfg.errorCodes = parseExitCodes(errorCodeStr)
+	// A template alone selects the template format
+	if templateStr != "" && cfg.format == "" {
+		cfg.format = formatTemplate
+	}
+
+	formats := []string{formatJSONL}
+	operation := cfg.subcommand
+	switch {
+	case cfg.subcommand == cmdRun:
+		formats = runFormats
+	case cfg.subcommand == cmdVerify:
+		formats = []string{formatText, formatJSONL}
+	case cfg.dbAction == "import":
+		formats = append([]string{formatAuto}, manifestFormats...)
+		operation += " import"
+	case cfg.dbAction == "export":
+		formats = []string{formatCoreutils, formatBSD, formatSidecar, formatJSONL}
+		operation += " export"
+	case cfg.dbAction == "convert":
+		formats = []string{formatBinary, formatJSONL}
+		operation += " convert"
+	}
+	if cfg.format == "" {
+		cfg.format = formats[0]
+	}
+	if !slices.Contains(formats, cfg.format) {
+		fmt.Fprintf(os.Stderr, "Error: invalid format '%s' for %s (expected %s)\n", cfg.format, operation, strings.Join(formats, ", "))
+		os.Exit(exitUsage)
+	}
+	if (cfg.format == formatTemplate) != (templateStr != "") {
+		fmt.Fprintln(os.Stderr, "Error: --template and --format template go together")
+		os.Exit(exitUsage)
+	}
+	if templateStr != "" {
+		tmpl, err := parseTemplate(templateStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid template: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		cfg.template = tmpl
+	}
+
+	// A dry run classifies files without executing commands or updating hashes
+	if cfg.dryRun {
+		cfg.update = false
+	}
+
 	cfg.filterOnCodes = len(cfg.successCodes) > 0 || len(cfg.errorCodes) > 0
 
 	return cfg
@@ -171,7 +597,7 @@ func getFiles() []string {
 
 	if err := scanner.Err(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading filenames from stdin: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	return files
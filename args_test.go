@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"io"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -157,3 +159,48 @@ func TestLogErrorConcurrent(t *testing.T) {
 		t.Errorf("expected some output")
 	}
 }
+
+func TestParseFlagsSubcommands(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name       string
+		args       []string
+		subcommand string
+		dbAction   string
+		files      []string
+	}{
+		{"legacy flags alias run", []string{"-c", "true", "a.txt"}, cmdRun, "", []string{"a.txt"}},
+		{"explicit run", []string{"run", "-c", "true", "a.txt"}, cmdRun, "", []string{"a.txt"}},
+		{"status without command", []string{"status", "-f", "h.jsonl", "a.txt"}, cmdStatus, "", []string{"a.txt"}},
+		{"dry run without command", []string{"--dry-run", "-f", "h.jsonl", "a.txt"}, cmdRun, "", []string{"a.txt"}},
+		{"verify", []string{"verify", "-f", "h.jsonl"}, cmdVerify, "", nil},
+		{"prune", []string{"prune", "-f", "h.jsonl"}, cmdPrune, "", nil},
+		{"run with prune", []string{"-c", "true", "-f", "h.jsonl", "--prune", "--prune-outside", "src,lib"}, cmdRun, "", nil},
+		{"db action", []string{"db", "get", "-f", "h.jsonl", "a.txt"}, cmdDB, "get", []string{"a.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"ghc"}, tt.args...)
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+			cfg := parseFlags()
+			if cfg.subcommand != tt.subcommand {
+				t.Errorf("expected subcommand %s, got %s", tt.subcommand, cfg.subcommand)
+			}
+			if cfg.dbAction != tt.dbAction {
+				t.Errorf("expected db action %q, got %q", tt.dbAction, cfg.dbAction)
+			}
+			expectDryRun := tt.subcommand == cmdStatus || slices.Contains(tt.args, "--dry-run")
+			if cfg.dryRun != expectDryRun {
+				t.Errorf("expected dry run %v, got %v", expectDryRun, cfg.dryRun)
+			}
+			if len(flag.Args()) != len(tt.files) {
+				t.Errorf("expected args %v, got %v", tt.files, flag.Args())
+			}
+		})
+	}
+}
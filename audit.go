@@ -3,11 +3,26 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io"
+	"maps"
 	"os"
+	"slices"
 )
 
-func loadAuditFile(filename string) map[string]string {
+// equal reports whether e and other are the same entry. CheckedAt is compared
+// as an instant, as entries read back from a file lose the monotonic clock
+// reading and location of the time they were created with.
+func (e AuditEntry) equal(other AuditEntry) bool {
+	return e.Filename == other.Filename &&
+		e.Hash == other.Hash &&
+		e.ExitCode == other.ExitCode &&
+		e.OutputBlob == other.OutputBlob &&
+		e.Rule == other.Rule &&
+		e.Check == other.Check &&
+		e.CheckedAt.Equal(other.CheckedAt) &&
+		e.RenamedFrom == other.RenamedFrom
+}
+
+func loadAuditFile(filename string) map[string]AuditEntry {
 	if filename == "" {
 		return nil
 	}
@@ -19,32 +34,54 @@ func loadAuditFile(filename string) map[string]string {
 			// Create empty file
 			if newFile, createErr := os.Create(filename); createErr == nil {
 				newFile.Close()
-				return make(map[string]string)
+				return make(map[string]AuditEntry)
 			} else {
 				fmt.Fprintf(os.Stderr, "Error creating hashes file: %v\n", createErr)
-				os.Exit(1)
+				os.Exit(exitError)
 			}
 		} else {
 			fmt.Fprintf(os.Stderr, "Error opening hashes file: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 	}
 	defer f.Close()
 
-	auditMap := make(map[string]string)
-	decoder := json.NewDecoder(f)
-
-	for {
-		var entry AuditEntry
-		err := decoder.Decode(&entry)
-		if err == io.EOF {
-			break
+	switch detectFormat(filename) {
+	case formatBinary:
+		// A run needs every entry, so the whole file is decoded; only db get
+		// and delta log updates look entries up in the mapped file
+		auditMap, err := readHashDB(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading hashes file: %v\n", err)
+			os.Exit(exitError)
 		}
+		return auditMap
+
+	case formatJSONL:
+		// Later records win, and removals written in journal mode drop entries
+		records, err := readJournal(f)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading hashes file: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
+		}
+		auditMap := make(map[string]AuditEntry, len(records))
+		for name, record := range records {
+			if !record.Deleted {
+				auditMap[name] = record.AuditEntry
+			}
 		}
-		auditMap[entry.Filename] = entry.Hash
+		return auditMap
+	}
+
+	// The format is detected, so coreutils, BSD and sidecar manifests can be
+	// used as hashes files directly
+	auditMap := make(map[string]AuditEntry)
+	err = readManifest(f, formatAuto, filename, func(entry AuditEntry) {
+		auditMap[entry.Filename] = entry
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading hashes file: %v\n", err)
+		os.Exit(exitError)
 	}
 
 	return auditMap
@@ -58,12 +95,6 @@ func mergeHashFiles(hashesFile string) {
 		return // No .new file to merge
 	}
 
-	// Load existing hashes
-	existingHashes := loadAuditFile(hashesFile)
-	if existingHashes == nil {
-		existingHashes = make(map[string]string)
-	}
-
 	// Load new hashes
 	newHashes := loadAuditFile(newFile)
 	if newHashes == nil {
@@ -71,27 +102,103 @@ func mergeHashFiles(hashesFile string) {
 		return
 	}
 
-	// Merge new hashes into existing ones (overwrites existing entries for same filename)
-	for filename, hash := range newHashes {
-		existingHashes[filename] = hash
+	// Entries of renamed files move to their new path, unless the old path
+	// got an entry of its own
+	var removed []string
+	for _, entry := range newHashes {
+		if _, readded := newHashes[entry.RenamedFrom]; entry.RenamedFrom != "" && !readded {
+			removed = append(removed, entry.RenamedFrom)
+		}
+	}
+	updated := make([]AuditEntry, 0, len(newHashes))
+	for _, filename := range slices.Sorted(maps.Keys(newHashes)) {
+		entry := newHashes[filename]
+		entry.RenamedFrom = ""
+		updated = append(updated, entry)
 	}
 
-	// Write merged hashes back to the original file
-	f, err := os.Create(hashesFile)
+	// A binary hashes file only gets the changes appended to its delta log
+	if detectFormat(hashesFile) == formatBinary {
+		if err := updateHashDB(hashesFile, updated, removed); err != nil {
+			logError("Error updating hashes file: %v\n", err)
+			return
+		}
+		os.Remove(newFile)
+		return
+	}
+
+	// Journal appends and compaction by concurrent runs wait until the
+	// hashes file is replaced, so their records aren't lost
+	unlock, err := lockHashesFile(hashesFile)
 	if err != nil {
-		logError("Error creating merged hashes file: %v\n", err)
+		logError("Error locking hashes file: %v\n", err)
 		return
 	}
-	defer f.Close()
+	defer unlock()
 
-	encoder := json.NewEncoder(f)
-	for filename, hash := range existingHashes {
-		entry := AuditEntry{Filename: filename, Hash: hash}
-		if err := encoder.Encode(entry); err != nil {
-			logError("Error writing merged hash entry: %v\n", err)
-		}
+	// Load existing hashes
+	existingHashes := loadAuditFile(hashesFile)
+	if existingHashes == nil {
+		existingHashes = make(map[string]AuditEntry)
+	}
+
+	// Merge new hashes into existing ones (overwrites existing entries for same filename)
+	for _, filename := range removed {
+		delete(existingHashes, filename)
+	}
+	for _, entry := range updated {
+		existingHashes[entry.Filename] = entry
+	}
+
+	// Write merged hashes back to the original file
+	if err := writeAuditFile(hashesFile, existingHashes); err != nil {
+		logError("Error writing merged hashes file: %v\n", err)
+		return
 	}
 
 	// Remove the .new file after successful merge
 	os.Remove(newFile)
 }
+
+// checkWritable returns an error if filename is a manifest, which can't hold
+// check results. Writing it as JSONL would replace the user's manifest, so it
+// is only converted on request, with db convert or db import.
+func checkWritable(filename string) error {
+	switch format := detectFormat(filename); format {
+	case "", formatJSONL, formatBinary:
+		return nil
+	default:
+		return fmt.Errorf("hashes file '%s' is a %s manifest; convert it with 'ghc db convert --format jsonl' or import it into a hashes file with 'ghc db import' to write to it", filename, format)
+	}
+}
+
+// writeAuditFile replaces filename with the given entries, sorted by filename.
+// A binary hashes file stays binary, and manifests are left alone, see
+// checkWritable.
+func writeAuditFile(filename string, entries map[string]AuditEntry) error {
+	if err := checkWritable(filename); err != nil {
+		return err
+	}
+	if detectFormat(filename) == formatBinary {
+		return writeHashDB(filename, entries)
+	}
+	return writeJSONLFile(filename, entries)
+}
+
+// writeJSONLFile replaces filename with the given entries as JSONL, sorted by
+// filename.
+func writeJSONLFile(filename string, entries map[string]AuditEntry) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		if err := encoder.Encode(entries[name]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -152,8 +152,8 @@ func TestLoadAuditFile_ValidFile(t *testing.T) {
 	}
 
 	for _, entry := range entries {
-		if result[entry.Filename] != entry.Hash {
-			t.Errorf("expected hash %s for file %s, got %s", entry.Hash, entry.Filename, result[entry.Filename])
+		if result[entry.Filename].Hash != entry.Hash {
+			t.Errorf("expected hash %s for file %s, got %s", entry.Hash, entry.Filename, result[entry.Filename].Hash)
 		}
 	}
 }
@@ -254,8 +254,8 @@ func TestMergeHashFiles_NoNewFile(t *testing.T) {
 	if len(result) != 1 {
 		t.Errorf("expected 1 entry, got %d", len(result))
 	}
-	if result["file1.txt"] != "hash1" {
-		t.Errorf("expected hash1, got %s", result["file1.txt"])
+	if result["file1.txt"].Hash != "hash1" {
+		t.Errorf("expected hash1, got %s", result["file1.txt"].Hash)
 	}
 }
 
@@ -305,8 +305,8 @@ func TestMergeHashFiles_EmptyNewFile(t *testing.T) {
 	if len(result) != 1 {
 		t.Errorf("expected 1 entry, got %d", len(result))
 	}
-	if result["file1.txt"] != "hash1" {
-		t.Errorf("expected hash1, got %s", result["file1.txt"])
+	if result["file1.txt"].Hash != "hash1" {
+		t.Errorf("expected hash1, got %s", result["file1.txt"].Hash)
 	}
 }
 
@@ -379,8 +379,8 @@ func TestMergeHashFiles_SuccessfulMerge(t *testing.T) {
 	}
 
 	for filename, expectedHash := range expected {
-		if result[filename] != expectedHash {
-			t.Errorf("expected hash %s for file %s, got %s", expectedHash, filename, result[filename])
+		if result[filename].Hash != expectedHash {
+			t.Errorf("expected hash %s for file %s, got %s", expectedHash, filename, result[filename].Hash)
 		}
 	}
 }
@@ -443,8 +443,8 @@ func TestMergeHashFiles_NoExistingFile(t *testing.T) {
 	}
 
 	for _, entry := range newEntries {
-		if result[entry.Filename] != entry.Hash {
-			t.Errorf("expected hash %s for file %s, got %s", entry.Hash, entry.Filename, result[entry.Filename])
+		if result[entry.Filename].Hash != entry.Hash {
+			t.Errorf("expected hash %s for file %s, got %s", entry.Hash, entry.Filename, result[entry.Filename].Hash)
 		}
 	}
 }
@@ -527,15 +527,15 @@ func TestMergeHashFiles_OverwriteExisting(t *testing.T) {
 	}
 
 	// Verify the duplicate was overwritten with new hash
-	if result["duplicate.txt"] != "new_hash" {
-		t.Errorf("expected new_hash for duplicate.txt, got %s", result["duplicate.txt"])
+	if result["duplicate.txt"].Hash != "new_hash" {
+		t.Errorf("expected new_hash for duplicate.txt, got %s", result["duplicate.txt"].Hash)
 	}
 
 	// Verify other entries are preserved
-	if result["unique_old.txt"] != "hash1" {
-		t.Errorf("expected hash1 for unique_old.txt, got %s", result["unique_old.txt"])
+	if result["unique_old.txt"].Hash != "hash1" {
+		t.Errorf("expected hash1 for unique_old.txt, got %s", result["unique_old.txt"].Hash)
 	}
-	if result["unique_new.txt"] != "hash2" {
-		t.Errorf("expected hash2 for unique_new.txt, got %s", result["unique_new.txt"])
+	if result["unique_new.txt"].Hash != "hash2" {
+		t.Errorf("expected hash2 for unique_new.txt, got %s", result["unique_new.txt"].Hash)
 	}
 }
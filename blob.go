@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// blobDir returns the directory next to hashesFile that stores the output of
+// checks, one file per distinct output named by its SHA256.
+func blobDir(hashesFile string) string {
+	return hashesFile + ".blobs"
+}
+
+// blobPath returns the path of the blob with the given hash, fanned out into
+// subdirectories by the first two hex digits.
+func blobPath(dir, hash string) (string, error) {
+	if !isSHA256(hash) {
+		return "", fmt.Errorf("invalid blob hash '%s'", hash)
+	}
+	return filepath.Join(dir, hash[:2], hash), nil
+}
+
+// writeBlob stores data in dir and returns its hash. Identical data is only
+// stored once.
+func writeBlob(dir string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path, err := blobPath(dir, hash)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a blob is never seen half-written
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp.Name(), path)
+}
+
+// readBlob returns the data stored in dir under hash.
+func readBlob(dir, hash string) ([]byte, error) {
+	path, err := blobPath(dir, hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// pruneBlobs removes the blobs in dir that no entry of auditMap refers to and
+// returns how many were removed.
+func pruneBlobs(dir string, auditMap map[string]AuditEntry) (int, error) {
+	referenced := make(map[string]bool)
+	for _, entry := range auditMap {
+		if entry.OutputBlob != "" {
+			referenced[entry.OutputBlob] = true
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "??", "*"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		if referenced[filepath.Base(path)] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
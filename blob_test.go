@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReadBlob(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hashes.jsonl.blobs")
+
+	hash, err := writeBlob(dir, []byte("output"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := writeBlob(dir, []byte("output"))
+	if err != nil || again != hash {
+		t.Errorf("expected identical data to give the same blob, got %s, %v", again, err)
+	}
+
+	data, err := readBlob(dir, hash)
+	if err != nil || string(data) != "output" {
+		t.Errorf("expected blob content, got %q, %v", data, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, hash[:2], hash)); err != nil {
+		t.Errorf("expected blob in fan-out directory: %v", err)
+	}
+
+	for _, bad := range []string{"", "abc", "../../../../etc/passwd", hash[:63] + "z"} {
+		if _, err := readBlob(dir, bad); err == nil {
+			t.Errorf("expected error for invalid blob hash %q", bad)
+		}
+	}
+}
+
+func TestPruneBlobs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hashes.jsonl.blobs")
+	used, err := writeBlob(dir, []byte("used"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := writeBlob(dir, []byte("unused"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditMap := map[string]AuditEntry{"a.txt": {Filename: "a.txt", Hash: "hash1", OutputBlob: used}}
+	removed, err := pruneBlobs(dir, auditMap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 blob removed, got %d", removed)
+	}
+	if _, err := readBlob(dir, used); err != nil {
+		t.Error("expected referenced blob to be kept")
+	}
+	if _, err := readBlob(dir, unused); err == nil {
+		t.Error("expected unreferenced blob to be removed")
+	}
+
+	if removed, err := pruneBlobs(filepath.Join(t.TempDir(), "none"), nil); err != nil || removed != 0 {
+		t.Errorf("expected nothing to do without blob directory, got %d, %v", removed, err)
+	}
+}
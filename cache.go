@@ -0,0 +1,72 @@
+package main
+
+import "bytes"
+
+// outputLimit caps the command output cached for a file.
+const outputLimit = 1024 * 1024
+
+// limitedBuffer keeps the first outputLimit bytes written to it and drops
+// the rest, so a noisy check can't fill the blob directory.
+type limitedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := outputLimit - b.Len()
+	if len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// String returns the captured output, marked if it was cut off.
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.Buffer.String() + "\n[output truncated]\n"
+	}
+	return b.Buffer.String()
+}
+
+// cacheable reports whether a result is stored in the hashes file on update.
+// Failures are cached too, so unchanged failing files aren't re-run; only
+// commands that could not be started (-1) are left out.
+func cacheable(result *Result) bool {
+	return result.ExitCode != -1
+}
+
+// checkChanged reports whether the stored result of a file came from other
+// checks than the ones that apply to it now, so it can't be replayed.
+// Entries written before checks were recorded are taken to come from the
+// current ones.
+func checkChanged(result *Result, stored AuditEntry) bool {
+	return result.Audited && stored.Check != "" && stored.Check != result.check
+}
+
+// cachedOutput returns the output stored for entry in the blob directory of
+// the hashes file, or "" if there is none.
+func cachedOutput(cfg Config, entry AuditEntry) string {
+	if entry.OutputBlob == "" {
+		return ""
+	}
+	data, err := readBlob(blobDir(cfg.hashesFile), entry.OutputBlob)
+	if err != nil {
+		if !cfg.quiet {
+			logError("Cached output for %s is unavailable: %v\n", entry.Filename, err)
+		}
+		return ""
+	}
+	return string(data)
+}
+
+// replayOutput writes the cached output of a skipped file where the check's
+// own output would have gone.
+func replayOutput(output string) {
+	if output != "" {
+		logError("%s", output)
+	}
+}
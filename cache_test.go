@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	var buf limitedBuffer
+	buf.Write([]byte("short"))
+	if buf.String() != "short" {
+		t.Errorf("expected output to be kept, got %q", buf.String())
+	}
+
+	n, err := buf.Write(make([]byte, outputLimit))
+	if err != nil || n != outputLimit {
+		t.Errorf("expected write to report success, got %d, %v", n, err)
+	}
+	if buf.Len() != outputLimit {
+		t.Errorf("expected %d bytes kept, got %d", outputLimit, buf.Len())
+	}
+	if !strings.HasSuffix(buf.String(), "[output truncated]\n") {
+		t.Error("expected truncated output to be marked")
+	}
+}
+
+func TestProcessFileCapturesOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "legacy.txt")
+	if err := os.WriteFile(file, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{command: "cat $FILE; exit 3", quiet: true}
+	result := processFile(file, cfg, nil)
+	if result == nil || result.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %+v", result)
+	}
+	if result.output != "broken" {
+		t.Errorf("expected command output to be captured, got %q", result.output)
+	}
+
+	cfg.command = "cat $FILE"
+	if result := processFile(file, cfg, nil); result.output != "broken" {
+		t.Errorf("expected output of a passing check to be captured too, got %q", result.output)
+	}
+}
+
+func TestProcessFileReplaysCachedFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "legacy.txt")
+	if err := os.WriteFile(file, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditMap := map[string]AuditEntry{file: {Filename: file, Hash: hash, ExitCode: 3}}
+
+	// The command would pass, so exit code 3 shows the failure was replayed
+	cfg := Config{command: "true", audit: true, quiet: true}
+	result := processFile(file, cfg, auditMap)
+	if result == nil || result.ExitCode != 3 || !result.Cached {
+		t.Errorf("expected cached failure to be replayed, got %+v", result)
+	}
+
+	cfg.rerunFailed = true
+	result = processFile(file, cfg, auditMap)
+	if result == nil || result.ExitCode != 0 || result.Cached {
+		t.Errorf("expected --rerun-failed to run the command, got %+v", result)
+	}
+
+	cfg.rerunFailed = false
+	cfg.successCodes = map[int]bool{0: true}
+	cfg.filterOnCodes = true
+	if result := processFile(file, cfg, auditMap); result != nil {
+		t.Errorf("expected replayed failure to be filtered, got %+v", result)
+	}
+}
+
+func TestProcessFilesReplaysCachedOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	file := filepath.Join(tempDir, "legacy.txt")
+	if err := os.WriteFile(file, []byte("warning: deprecated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{command: "cat $FILE; exit 2", audit: true, update: true, hashesFile: hashesFile, workers: 1, quiet: true}
+	processFiles([]string{file}, cfg, map[string]AuditEntry{file: {Filename: file, Hash: "old"}}, io.Discard)
+	mergeHashFiles(hashesFile)
+
+	entry := loadAuditFile(hashesFile)[file]
+	if entry.ExitCode != 2 || entry.OutputBlob == "" {
+		t.Fatalf("expected exit code and output blob to be stored, got %+v", entry)
+	}
+	if data, err := readBlob(blobDir(hashesFile), entry.OutputBlob); err != nil || string(data) != "warning: deprecated" {
+		t.Errorf("expected output in blob, got %q, %v", data, err)
+	}
+
+	// The unchanged file is skipped and its output and exit code replayed
+	cfg.update = false
+	result := processFile(file, cfg, loadAuditFile(hashesFile))
+	if result == nil || !result.Cached || result.ExitCode != 2 || result.output != "warning: deprecated" {
+		t.Errorf("expected cached result to be replayed, got %+v", result)
+	}
+}
+
+func TestProcessFileRerunsChangedCheck(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "legacy.txt")
+	if err := os.WriteFile(file, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failure was cached by a check that has since been fixed
+	cfg := Config{command: "true", audit: true, quiet: true}
+	check := checkID(rulesFor(cfg, file), cfg)
+	auditMap := map[string]AuditEntry{file: {Filename: file, Hash: hash, ExitCode: 3, Check: "0123456789abcdef"}}
+	result := processFile(file, cfg, auditMap)
+	if result == nil || result.ExitCode != 0 || result.Cached || result.check != check {
+		t.Errorf("expected a changed check to run the command, got %+v", result)
+	}
+
+	auditMap[file] = AuditEntry{Filename: file, Hash: hash, ExitCode: 3, Check: check}
+	result = processFile(file, cfg, auditMap)
+	if result == nil || result.ExitCode != 3 || !result.Cached {
+		t.Errorf("expected the failure cached by the same check to be replayed, got %+v", result)
+	}
+}
+
+func TestProcessFileChecksUnknownFile(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "new.txt")
+	if err := os.WriteFile(file, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file without an entry has no result to replay
+	cfg := Config{command: "exit 4", audit: true, quiet: true}
+	auditMap := map[string]AuditEntry{"other.txt": {Filename: "other.txt", Hash: "abc"}}
+	result := processFile(file, cfg, auditMap)
+	if result == nil || result.ExitCode != 4 || result.Cached || result.Audited {
+		t.Errorf("expected a file unknown to the hashes file to be checked, got %+v", result)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are searched for in the working directory and each of its
+// parents when no --config flag is given.
+var configFileNames = []string{".ghc.yaml", ".ghc.yml", ".ghc.json"}
+
+// FileConfig is the project configuration read from .ghc.yaml or .ghc.json.
+// Pointer fields distinguish "not set" from an explicit false or zero, so a
+// profile can override the base section either way.
+type FileConfig struct {
+	HashesFile        string                `yaml:"hashes_file" json:"hashes_file"`
+	Command           string                `yaml:"command" json:"command"`
+	Workers           int                   `yaml:"workers" json:"workers"`
+	Audit             *bool                 `yaml:"audit" json:"audit"`
+	Update            *bool                 `yaml:"update" json:"update"`
+	Progress          *bool                 `yaml:"progress" json:"progress"`
+	Quiet             *bool                 `yaml:"quiet" json:"quiet"`
+	TrustContent      *bool                 `yaml:"trust_content" json:"trust_content"`
+	Journal           *bool                 `yaml:"journal" json:"journal"`
+	SuccessExitCodes  []int                 `yaml:"success_exit_codes" json:"success_exit_codes"`
+	ErrorExitCodes    []int                 `yaml:"error_exit_codes" json:"error_exit_codes"`
+	Timeout           string                `yaml:"timeout" json:"timeout"`
+	MaxAge            string                `yaml:"max_age" json:"max_age"`
+	Include           []string              `yaml:"include" json:"include"`
+	Exclude           []string              `yaml:"exclude" json:"exclude"`
+	Rules             []Rule                `yaml:"rules" json:"rules"`
+	RuleMode          string                `yaml:"rule_mode" json:"rule_mode"`
+	Unmatched         string                `yaml:"unmatched" json:"unmatched"`
+	FailOn            string                `yaml:"fail_on" json:"fail_on"`
+	FailFast          *bool                 `yaml:"fail_fast" json:"fail_fast"`
+	History           *bool                 `yaml:"history" json:"history"`
+	DiagnosticPattern string                `yaml:"diagnostic_pattern" json:"diagnostic_pattern"`
+	Profiles          map[string]FileConfig `yaml:"profiles" json:"profiles"`
+}
+
+// findConfigFile searches dir and its parents for a config file and returns
+// its path, or "" if there is none.
+func findConfigFile(dir string) string {
+	for {
+		for _, name := range configFileNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadConfigFile reads the config file at path and applies the named profile
+// on top of its base section. Relative hashes_file paths are resolved against
+// the directory containing the config file.
+func loadConfigFile(path, profile string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fc FileConfig
+	if strings.HasSuffix(path, ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&fc)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&fc)
+		if err == io.EOF {
+			err = nil // empty file
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if profile != "" {
+		overlay, ok := fc.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' not found in %s", profile, path)
+		}
+		fc.merge(overlay)
+	}
+	fc.Profiles = nil
+
+	if fc.HashesFile != "" && !filepath.IsAbs(fc.HashesFile) {
+		fc.HashesFile = filepath.Join(filepath.Dir(path), fc.HashesFile)
+	}
+
+	return &fc, nil
+}
+
+// merge overrides fc with every field that is set in overlay.
+func (fc *FileConfig) merge(overlay FileConfig) {
+	if overlay.HashesFile != "" {
+		fc.HashesFile = overlay.HashesFile
+	}
+	if overlay.Command != "" {
+		fc.Command = overlay.Command
+	}
+	if overlay.Workers != 0 {
+		fc.Workers = overlay.Workers
+	}
+	if overlay.Audit != nil {
+		fc.Audit = overlay.Audit
+	}
+	if overlay.Update != nil {
+		fc.Update = overlay.Update
+	}
+	if overlay.Progress != nil {
+		fc.Progress = overlay.Progress
+	}
+	if overlay.Quiet != nil {
+		fc.Quiet = overlay.Quiet
+	}
+	if overlay.TrustContent != nil {
+		fc.TrustContent = overlay.TrustContent
+	}
+	if overlay.Journal != nil {
+		fc.Journal = overlay.Journal
+	}
+	if overlay.SuccessExitCodes != nil {
+		fc.SuccessExitCodes = overlay.SuccessExitCodes
+	}
+	if overlay.ErrorExitCodes != nil {
+		fc.ErrorExitCodes = overlay.ErrorExitCodes
+	}
+	if overlay.Timeout != "" {
+		fc.Timeout = overlay.Timeout
+	}
+	if overlay.MaxAge != "" {
+		fc.MaxAge = overlay.MaxAge
+	}
+	if overlay.Include != nil {
+		fc.Include = overlay.Include
+	}
+	if overlay.Exclude != nil {
+		fc.Exclude = overlay.Exclude
+	}
+	if overlay.Rules != nil {
+		fc.Rules = overlay.Rules
+	}
+	if overlay.RuleMode != "" {
+		fc.RuleMode = overlay.RuleMode
+	}
+	if overlay.Unmatched != "" {
+		fc.Unmatched = overlay.Unmatched
+	}
+	if overlay.FailOn != "" {
+		fc.FailOn = overlay.FailOn
+	}
+	if overlay.FailFast != nil {
+		fc.FailFast = overlay.FailFast
+	}
+	if overlay.History != nil {
+		fc.History = overlay.History
+	}
+	if overlay.DiagnosticPattern != "" {
+		fc.DiagnosticPattern = overlay.DiagnosticPattern
+	}
+}
+
+// applyFileConfig copies settings from the config file into cfg, skipping
+// any setting whose command-line flag was given explicitly.
+func applyFileConfig(cfg *Config, fc *FileConfig, setFlags map[string]bool) error {
+	isSet := func(names ...string) bool {
+		for _, name := range names {
+			if setFlags[name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	if fc.HashesFile != "" && !isSet("f", "hashes-file") {
+		cfg.hashesFile = fc.HashesFile
+	}
+	if fc.Command != "" && !isSet("c", "check-command") {
+		cfg.command = fc.Command
+	}
+	if fc.Workers != 0 && !isSet("w", "workers") {
+		cfg.workers = fc.Workers
+	}
+	if fc.Audit != nil && !isSet("a", "audit") {
+		cfg.audit = *fc.Audit
+	}
+	if fc.Update != nil && !isSet("u", "update") {
+		cfg.update = *fc.Update
+	}
+	if fc.Progress != nil && !isSet("p", "progress") {
+		cfg.showProgress = *fc.Progress
+	}
+	if fc.Quiet != nil && !isSet("q", "quiet") {
+		cfg.quiet = *fc.Quiet
+	}
+	if fc.TrustContent != nil && !isSet("trust-content") {
+		cfg.trustContent = *fc.TrustContent
+	}
+	if fc.Journal != nil && !isSet("journal") {
+		cfg.journal = *fc.Journal
+	}
+	if fc.SuccessExitCodes != nil && !isSet("success-exit-codes") {
+		cfg.successCodes = exitCodeSet(fc.SuccessExitCodes)
+	}
+	if fc.ErrorExitCodes != nil && !isSet("error-exit-codes") {
+		cfg.errorCodes = exitCodeSet(fc.ErrorExitCodes)
+	}
+	if fc.Timeout != "" && !isSet("timeout") {
+		timeout, err := time.ParseDuration(fc.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %w", fc.Timeout, err)
+		}
+		cfg.timeout = timeout
+	}
+	if fc.MaxAge != "" && !isSet("max-age") {
+		maxAge, err := parseAge(fc.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid max age '%s': %w", fc.MaxAge, err)
+		}
+		cfg.maxAge = maxAge
+	}
+	if fc.Include != nil && !isSet("include") {
+		cfg.include = fc.Include
+	}
+	if fc.Exclude != nil && !isSet("exclude") {
+		cfg.exclude = fc.Exclude
+	}
+	if fc.RuleMode != "" && !isSet("rule-mode") {
+		cfg.ruleMode = fc.RuleMode
+	}
+	if fc.Unmatched != "" && !isSet("unmatched") {
+		cfg.unmatched = fc.Unmatched
+	}
+	if fc.FailOn != "" && !isSet("fail-on") {
+		cfg.failOn = fc.FailOn
+	}
+	if fc.FailFast != nil && !isSet("fail-fast") {
+		cfg.failFast = *fc.FailFast
+	}
+	if fc.History != nil && !isSet("history") {
+		cfg.history = *fc.History
+	}
+	if fc.DiagnosticPattern != "" && !isSet("diagnostic-pattern") {
+		pattern, err := parseDiagnosticPattern(fc.DiagnosticPattern)
+		if err != nil {
+			return fmt.Errorf("invalid diagnostic pattern '%s': %w", fc.DiagnosticPattern, err)
+		}
+		cfg.diagnosticPattern = pattern
+	}
+
+	if err := validateRules(fc.Rules); err != nil {
+		return err
+	}
+	// A check command given on the command line replaces the rules too
+	if !isSet("c", "check-command") {
+		cfg.rules = fc.Rules
+	}
+
+	return nil
+}
+
+func exitCodeSet(codes []int) map[int]bool {
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfigYAML = `hashes_file: hashes.jsonl
+command: "mycheck"
+workers: 3
+audit: true
+timeout: 30s
+success_exit_codes: [0]
+error_exit_codes: [1, 2]
+include: ["*.go"]
+exclude: ["vendor/**"]
+rules:
+  - patterns: ["*.sh"]
+    command: "shellcheck"
+profiles:
+  ci:
+    workers: 8
+    quiet: true
+    audit: false
+`
+
+func TestFindConfigFile(t *testing.T) {
+	tempDir := t.TempDir()
+	nested := filepath.Join(tempDir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := findConfigFile(nested); filepath.Dir(found) == tempDir {
+		t.Errorf("expected no config file, got %s", found)
+	}
+
+	configPath := filepath.Join(tempDir, ".ghc.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := findConfigFile(nested); found != configPath {
+		t.Errorf("expected %s, got %s", configPath, found)
+	}
+}
+
+func TestLoadConfigFile_YAML(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, ".ghc.yaml")
+	if err := os.WriteFile(configPath, []byte(testConfigYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := loadConfigFile(configPath, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fc.HashesFile != filepath.Join(tempDir, "hashes.jsonl") {
+		t.Errorf("expected hashes file relative to config dir, got %s", fc.HashesFile)
+	}
+	if fc.Command != "mycheck" || fc.Workers != 3 {
+		t.Errorf("unexpected command/workers: %q/%d", fc.Command, fc.Workers)
+	}
+	if fc.Audit == nil || !*fc.Audit {
+		t.Error("expected audit to be true")
+	}
+	if len(fc.Rules) != 1 || fc.Rules[0].Command != "shellcheck" {
+		t.Errorf("unexpected rules: %+v", fc.Rules)
+	}
+}
+
+func TestLoadConfigFile_Profile(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, ".ghc.yaml")
+	if err := os.WriteFile(configPath, []byte(testConfigYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := loadConfigFile(configPath, "ci")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fc.Workers != 8 {
+		t.Errorf("expected profile workers 8, got %d", fc.Workers)
+	}
+	if fc.Audit == nil || *fc.Audit {
+		t.Error("expected profile to override audit to false")
+	}
+	if fc.Command != "mycheck" {
+		t.Errorf("expected base command to be kept, got %q", fc.Command)
+	}
+
+	if _, err := loadConfigFile(configPath, "missing"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestLoadConfigFile_JSON(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, ".ghc.json")
+	content := `{"command": "jq empty", "workers": 2, "exclude": ["*.min.json"]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := loadConfigFile(configPath, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.Command != "jq empty" || fc.Workers != 2 || len(fc.Exclude) != 1 {
+		t.Errorf("unexpected config: %+v", fc)
+	}
+}
+
+func TestLoadConfigFile_UnknownField(t *testing.T) {
+	tempDir := t.TempDir()
+
+	yamlPath := filepath.Join(tempDir, ".ghc.yaml")
+	if err := os.WriteFile(yamlPath, []byte("comand: typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(yamlPath, ""); err == nil {
+		t.Error("expected error for unknown YAML field")
+	}
+
+	jsonPath := filepath.Join(tempDir, ".ghc.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"comand": "typo"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(jsonPath, ""); err == nil {
+		t.Error("expected error for unknown JSON field")
+	}
+}
+
+func TestApplyFileConfig(t *testing.T) {
+	audit := true
+	fc := &FileConfig{
+		HashesFile:       "hashes.jsonl",
+		Command:          "mycheck",
+		Workers:          3,
+		Audit:            &audit,
+		SuccessExitCodes: []int{0},
+		Timeout:          "1m",
+		Include:          []string{"*.go"},
+		Rules:            []Rule{{Patterns: []string{"*.go"}, Command: "gofmt -l"}},
+	}
+
+	cfg := Config{command: "from-flag"}
+	setFlags := map[string]bool{"c": true}
+	if err := applyFileConfig(&cfg, fc, setFlags); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.command != "from-flag" {
+		t.Errorf("expected flag to override config command, got %q", cfg.command)
+	}
+	if rules := rulesFor(cfg, "main.go"); len(rules) != 1 || rules[0].Command != "from-flag" {
+		t.Errorf("expected flag to override config rules, got %+v", rules)
+	}
+	if cfg.hashesFile != "hashes.jsonl" || cfg.workers != 3 || !cfg.audit {
+		t.Errorf("expected config values to be applied: %+v", cfg)
+	}
+	if !cfg.successCodes[0] {
+		t.Error("expected success exit code 0 from config")
+	}
+	if cfg.timeout != time.Minute {
+		t.Errorf("expected timeout 1m, got %s", cfg.timeout)
+	}
+
+	fc.Timeout = "soon"
+	if err := applyFileConfig(&cfg, fc, nil); err == nil {
+		t.Error("expected error for invalid timeout")
+	}
+}
+
+func TestParseFlagsWithConfigFile(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, ".ghc.yaml"), []byte(testConfigYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(tempDir)
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"test", "--profile", "ci", "-w", "5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	cfg := parseFlags()
+
+	if cfg.workers != 5 {
+		t.Errorf("expected -w to override profile workers, got %d", cfg.workers)
+	}
+	if !cfg.quiet {
+		t.Error("expected quiet from ci profile")
+	}
+	if cfg.command != "mycheck" {
+		t.Errorf("expected command from config, got %q", cfg.command)
+	}
+	if !cfg.filterOnCodes || !cfg.errorCodes[2] {
+		t.Error("expected exit code filter from config")
+	}
+}
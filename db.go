@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// runDB implements the db command: action is one of list, get, set, rm,
+// import, export, compact or convert and args are its arguments. It returns
+// the process exit code.
+func runDB(cfg Config, args []string, output io.Writer) int {
+	encoder := json.NewEncoder(output)
+
+	// A binary hashes file answers lookups without being loaded
+	if cfg.dbAction == "get" && detectFormat(cfg.hashesFile) == formatBinary {
+		db, err := openHashDB(cfg.hashesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading hashes file: %v\n", err)
+			return exitError
+		}
+		defer db.Close()
+		return getEntries(args, db.get, encoder)
+	}
+
+	auditMap := loadAuditFile(cfg.hashesFile)
+
+	switch cfg.dbAction {
+	case "list":
+		for _, name := range slices.Sorted(maps.Keys(auditMap)) {
+			if err := encoder.Encode(auditMap[name]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding entry: %v\n", err)
+				return exitError
+			}
+		}
+		return 0
+
+	case "get":
+		return getEntries(args, func(name string) (AuditEntry, bool) {
+			entry, ok := auditMap[name]
+			return entry, ok
+		}, encoder)
+
+	case "set":
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Fprintln(os.Stderr, "Error: db set requires FILE and an optional HASH")
+			return exitUsage
+		}
+		entry := AuditEntry{Filename: args[0]}
+		if len(args) == 2 {
+			// Whatever the format of the hashes file, only SHA256 digests go in
+			if !isSHA256(args[1]) {
+				fmt.Fprintf(os.Stderr, "Error: '%s' is not a SHA256 hash\n", args[1])
+				return exitUsage
+			}
+			entry.Hash = strings.ToLower(args[1])
+		} else {
+			hash, err := hashFile(entry.Filename)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error hashing %s: %v\n", entry.Filename, err)
+				return exitError
+			}
+			entry.Hash = hash
+		}
+		auditMap[entry.Filename] = entry
+
+	case "rm":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: db rm requires at least one FILE")
+			return exitUsage
+		}
+		for _, name := range args {
+			if _, ok := auditMap[name]; !ok {
+				fmt.Fprintf(os.Stderr, "No entry for %s\n", name)
+				continue
+			}
+			delete(auditMap, name)
+		}
+
+	case "import":
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: db import requires at least one MANIFEST")
+			return exitUsage
+		}
+		for _, name := range args {
+			count, err := importManifest(name, cfg.format, auditMap)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", name, err)
+				return exitError
+			}
+			fmt.Fprintf(os.Stderr, "Imported %d entries from %s\n", count, name)
+		}
+
+	case "export":
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: db export takes at most one OUTPUT file")
+			return exitUsage
+		}
+		entries := make([]AuditEntry, 0, len(auditMap))
+		for _, name := range slices.Sorted(maps.Keys(auditMap)) {
+			entries = append(entries, auditMap[name])
+		}
+		return exportManifest(entries, cfg.format, args, output)
+
+	case "compact":
+		count, err := compactHashesFile(cfg.hashesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing hashes file: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "Compacted hashes file to %d entries\n", count)
+		return 0
+
+	case "convert":
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: db convert takes at most one OUTPUT file")
+			return exitUsage
+		}
+		target := cfg.hashesFile
+		if len(args) == 1 {
+			target = args[0]
+		}
+		if err := convertHashesFile(target, cfg.format, auditMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", target, err)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "Converted %d entries to %s format in %s\n", len(auditMap), cfg.format, target)
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown db action '%s' (expected list, get, set, rm, import, export, compact or convert)\n", cfg.dbAction)
+		return exitUsage
+	}
+
+	if err := writeAuditFile(cfg.hashesFile, auditMap); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing hashes file: %v\n", err)
+		return exitError
+	}
+	return 0
+}
+
+// getEntries prints the entries lookup finds for names, returning 1 if any
+// is missing.
+func getEntries(names []string, lookup func(string) (AuditEntry, bool), encoder *json.Encoder) int {
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: db get requires at least one FILE")
+		return exitUsage
+	}
+	exitCode := 0
+	for _, name := range names {
+		entry, ok := lookup(name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "No entry for %s\n", name)
+			exitCode = 1
+			continue
+		}
+		if err := encoder.Encode(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding entry: %v\n", err)
+			return exitError
+		}
+	}
+	return exitCode
+}
+
+// convertHashesFile writes entries to filename in the binary or JSONL
+// format, replacing it and any delta log it had as a binary hashes file.
+func convertHashesFile(filename, format string, entries map[string]AuditEntry) error {
+	if format == formatBinary {
+		return writeHashDB(filename, entries)
+	}
+	if err := writeJSONLFile(filename, entries); err != nil {
+		return err
+	}
+	if err := os.Remove(deltaLogPath(filename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// importManifest reads the manifest at path in the given format into
+// auditMap and returns the number of entries read. Imported entries replace
+// existing ones for the same file.
+func importManifest(path, format string, auditMap map[string]AuditEntry) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	err = readManifest(f, format, path, func(entry AuditEntry) {
+		auditMap[entry.Filename] = entry
+		count++
+	})
+	return count, err
+}
+
+// exportManifest writes entries in the given format to output, or to the
+// file named in args. Sidecar files are written next to each file and their
+// paths printed to output instead.
+func exportManifest(entries []AuditEntry, format string, args []string, output io.Writer) int {
+	if format == formatSidecar {
+		if len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: db export --format sidecar writes next to each file and takes no OUTPUT")
+			return exitUsage
+		}
+		written, err := writeSidecars(entries)
+		for _, path := range written {
+			fmt.Fprintln(output, path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing sidecar file: %v\n", err)
+			return exitError
+		}
+		return 0
+	}
+
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", args[0], err)
+			return exitError
+		}
+		defer f.Close()
+		output = f
+	}
+
+	if err := writeManifest(output, format, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing manifest: %v\n", err)
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDB(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	file := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuditFile(hashesFile, map[string]AuditEntry{"a.txt": {Filename: "a.txt", Hash: "hash1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	db := func(action string, args ...string) (int, string) {
+		var buf bytes.Buffer
+		cfg := Config{hashesFile: hashesFile, dbAction: action, quiet: true}
+		return runDB(cfg, args, &buf), buf.String()
+	}
+
+	t.Run("set with hash", func(t *testing.T) {
+		if code, _ := db("set", "b.txt", strings.ToUpper(testHash2)); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		if loadAuditFile(hashesFile)["b.txt"].Hash != testHash2 {
+			t.Error("expected b.txt to be stored with a lowercase hash")
+		}
+	})
+
+	t.Run("set rejects invalid hash", func(t *testing.T) {
+		for _, hash := range []string{"nothex", testHash2[:62] + "zz", testHash2 + "00"} {
+			if code, _ := db("set", "b.txt", hash); code != exitUsage {
+				t.Errorf("expected exit code %d for %q, got %d", exitUsage, hash, code)
+			}
+		}
+		if loadAuditFile(hashesFile)["b.txt"].Hash != testHash2 {
+			t.Error("expected b.txt to be left alone")
+		}
+	})
+
+	t.Run("set computes hash", func(t *testing.T) {
+		if code, _ := db("set", file); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		if loadAuditFile(hashesFile)[file].Hash != hash {
+			t.Error("expected current hash of file to be stored")
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		code, output := db("list")
+		if code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		if len(lines) != 3 || !strings.Contains(output, `"a.txt"`) {
+			t.Errorf("expected 3 entries, got:\n%s", output)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		code, output := db("get", "a.txt", "unknown.txt")
+		if code != 1 {
+			t.Errorf("expected exit code 1 for unknown file, got %d", code)
+		}
+		if !strings.Contains(output, `"hash1"`) || strings.Contains(output, "unknown.txt") {
+			t.Errorf("expected only a.txt entry, got %s", output)
+		}
+	})
+
+	t.Run("rm", func(t *testing.T) {
+		if code, _ := db("rm", "a.txt"); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		if _, exists := loadAuditFile(hashesFile)["a.txt"]; exists {
+			t.Error("expected a.txt to be removed")
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		manifest := filepath.Join(tempDir, "SHA256SUMS")
+		if err := os.WriteFile(manifest, []byte(testHash1+"  c.txt\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		cfg := Config{hashesFile: hashesFile, dbAction: "import", format: formatAuto}
+		if code := runDB(cfg, []string{manifest}, &buf); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		if loadAuditFile(hashesFile)["c.txt"].Hash != testHash1 {
+			t.Error("expected c.txt to be imported")
+		}
+		if detectFormat(hashesFile) != formatJSONL {
+			t.Error("expected hashes file to stay JSONL")
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		var buf bytes.Buffer
+		cfg := Config{hashesFile: hashesFile, dbAction: "export", format: formatBSD}
+		if code := runDB(cfg, nil, &buf); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		if !strings.Contains(buf.String(), "SHA256 (c.txt) = "+testHash1+"\n") {
+			t.Errorf("expected BSD line for c.txt, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if code, _ := db("set"); code != exitUsage {
+			t.Errorf("expected exit code %d without FILE, got %d", exitUsage, code)
+		}
+		if code, _ := db("set", filepath.Join(tempDir, "missing.txt")); code != exitError {
+			t.Errorf("expected exit code %d for an unreadable file, got %d", exitError, code)
+		}
+		if code, _ := db("export", filepath.Join(tempDir, "missing", "SHA256SUMS")); code != exitError {
+			t.Errorf("expected exit code %d for an unwritable OUTPUT, got %d", exitError, code)
+		}
+	})
+
+	t.Run("unknown action", func(t *testing.T) {
+		if code, _ := db("frobnicate"); code != exitUsage {
+			t.Errorf("expected exit code %d, got %d", exitUsage, code)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultDiagnosticPattern matches the "file:line:col: message" lines of
+// compilers and most linters; the column is optional.
+const defaultDiagnosticPattern = `^(?P<file>[^:\s]+):(?P<line>\d+):(?:(?P<col>\d+):)?\s*(?P<msg>.*)$`
+
+// diagnostic is a problem a failed check reported, located in a file and,
+// if its output said so, at a line and column.
+type diagnostic struct {
+	file    string
+	line    int
+	column  int
+	message string
+}
+
+// parseDiagnosticPattern compiles a --diagnostic-pattern. Its named groups
+// file, line, col and msg are all optional.
+func parseDiagnosticPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.SubexpIndex("line") < 0 && re.SubexpIndex("msg") < 0 {
+		return nil, fmt.Errorf("pattern needs a line or msg group")
+	}
+	return re, nil
+}
+
+// diagnostics returns the problems reported by the failed check of result:
+// one per line of its output matching pattern, or a single one for the
+// whole file if no line matches.
+func diagnostics(result *Result, pattern *regexp.Regexp) []diagnostic {
+	var found []diagnostic
+	if pattern != nil {
+		group := func(match []string, name string) string {
+			if i := pattern.SubexpIndex(name); i >= 0 {
+				return strings.TrimSpace(match[i])
+			}
+			return ""
+		}
+		for _, match := range pattern.FindAllStringSubmatch(result.output, -1) {
+			d := diagnostic{file: group(match, "file"), message: group(match, "msg")}
+			if d.file == "" {
+				d.file = result.Filename
+			}
+			d.line, _ = strconv.Atoi(group(match, "line"))
+			d.column, _ = strconv.Atoi(group(match, "col"))
+			if d.message == "" {
+				d.message = failureMessage(result)
+			}
+			found = append(found, d)
+		}
+	}
+	if len(found) == 0 {
+		found = append(found, diagnostic{file: result.Filename, message: failureMessage(result)})
+	}
+	return found
+}
+
+// failureMessage describes the outcome of a failed check.
+func failureMessage(result *Result) string {
+	if result.ExitCode == -1 {
+		return "command could not be run"
+	}
+	message := fmt.Sprintf("exit code %d", result.ExitCode)
+	if result.Cached {
+		message += " (cached)"
+	}
+	return message
+}
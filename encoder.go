@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats of a run for CI systems.
+const (
+	formatGitHub            = "github"
+	formatGitLabCodeQuality = "gitlab-codequality"
+)
+
+// runFormats are the --format values of the run command.
+var runFormats = []string{formatJSONL, formatText, formatTable, formatTemplate, formatGitHub, formatGitLabCodeQuality}
+
+// resultEncoder writes the results of a run to the output in the --format.
+// close is called once all results are written.
+type resultEncoder interface {
+	encode(result *Result) error
+	close() error
+}
+
+// newResultEncoder returns the encoder for cfg.format, JSONL by default.
+func newResultEncoder(cfg Config, output io.Writer) resultEncoder {
+	switch cfg.format {
+	case formatText:
+		return newTextEncoder(output)
+	case formatTable:
+		return newTableEncoder(output)
+	case formatTemplate:
+		return &templateEncoder{output: output, tmpl: cfg.template}
+	case formatGitHub:
+		return newGitHubEncoder(cfg, output)
+	case formatGitLabCodeQuality:
+		return newGitLabEncoder(cfg, output)
+	}
+	return jsonlEncoder{json.NewEncoder(output)}
+}
+
+// jsonlEncoder writes each result as a line of JSON.
+type jsonlEncoder struct {
+	*json.Encoder
+}
+
+func (e jsonlEncoder) encode(result *Result) error {
+	return e.Encode(jsonRecord(result))
+}
+
+func (e jsonlEncoder) close() error {
+	return nil
+}
+
+// relativePath returns path relative to the working directory if it is
+// inside it, with forward slashes, as CI annotations expect.
+func relativePath(path string) string {
+	if filepath.IsAbs(path) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
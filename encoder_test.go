@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGitHubEncoder(t *testing.T) {
+	summaryFile := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryFile)
+
+	var buf bytes.Buffer
+	cfg := Config{command: "lint", format: formatGitHub, diagnosticPattern: regexp.MustCompile("(?m)" + defaultDiagnosticPattern)}
+	encoder := newResultEncoder(cfg, &buf)
+	results := []*Result{
+		{Filename: "ok.go"},
+		{Filename: "same.go", Audited: true, Cached: true},
+		{Filename: "a.go", ExitCode: 1, failed: true, output: "a.go:3:7: 100% wrong, really\nnoise\n"},
+		{Filename: "b,c.go", Audited: true, Changed: true, ExitCode: 2, failed: true, output: "multi\nline"},
+	}
+	for _, result := range results {
+		if err := encoder.encode(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "::error file=a.go,line=3,col=7,title=lint::100%25 wrong, really\n" +
+		"::error file=b%2Cc.go,title=lint::exit code 2\n"
+	if buf.String() != want {
+		t.Errorf("expected workflow commands:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	summary, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{"| New | 2 |", "| Changed | 1 |", "| Unchanged | 1 |", "| Failed | 2 |"} {
+		if !strings.Contains(string(summary), row) {
+			t.Errorf("expected %q in step summary:\n%s", row, summary)
+		}
+	}
+}
+
+func TestGitLabEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{format: formatGitLabCodeQuality, diagnosticPattern: regexp.MustCompile("(?m)" + defaultDiagnosticPattern)}
+	encoder := newResultEncoder(cfg, &buf)
+	results := []*Result{
+		{Filename: "ok.go"},
+		{Filename: "b.go", ExitCode: 1, failed: true, Rule: "vet"},
+		{Filename: "a.go", ExitCode: 1, failed: true, Rule: "vet", output: "a.go:9: second\na.go:2: first\n"},
+	}
+	for _, result := range results {
+		if err := encoder.encode(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var issues []gitlabIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatalf("invalid report: %v\n%s", err, buf.String())
+	}
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %s", buf.String())
+	}
+	wantOrder := []string{"a.go:2:first", "a.go:9:second", "b.go:1:exit code 1"}
+	for i, issue := range issues {
+		got := issue.Location.Path + ":" + strconv.Itoa(issue.Location.Lines.Begin) + ":" + issue.Description
+		if got != wantOrder[i] || issue.CheckName != "vet" || len(issue.Fingerprint) != 64 {
+			t.Errorf("issue %d = %+v, want %s", i, issue, wantOrder[i])
+		}
+	}
+	if issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Error("expected distinct fingerprints")
+	}
+
+	// An empty report is still a valid array
+	buf.Reset()
+	empty := newResultEncoder(cfg, &buf)
+	if err := empty.close(); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("expected empty array, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"./src/a.go":                    "src/a.go",
+		filepath.Join(cwd, "src/a.go"):  "src/a.go",
+		"/elsewhere/a.go":               "/elsewhere/a.go",
+		filepath.Join(cwd, "../b/b.go"): filepath.ToSlash(filepath.Join(filepath.Dir(cwd), "b/b.go")),
+	}
+	for path, want := range tests {
+		if got := relativePath(path); got != want {
+			t.Errorf("relativePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
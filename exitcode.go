@@ -0,0 +1,45 @@
+package main
+
+// Exit statuses of a run. A status run exits 1 when files need checking,
+// and an interrupted run with interruptExitCode.
+const (
+	exitOK          = 0
+	exitCheckFailed = 1
+	exitUsage       = 2
+	exitError       = 3
+)
+
+// --fail-on policies: which outcomes of a run make ghc exit non-zero.
+const (
+	failOnFailure = "failure"
+	failOnError   = "error"
+	failOnNone    = "none"
+)
+
+var failOnPolicies = []string{failOnFailure, failOnError, failOnNone}
+
+// succeeded reports whether exitCode is a success for the check configured
+// by cfg: one of --success-exit-codes if given, and 0 otherwise.
+func succeeded(cfg Config, exitCode int) bool {
+	if len(cfg.successCodes) > 0 {
+		return cfg.successCodes[exitCode]
+	}
+	return exitCode == 0
+}
+
+// exitCode returns the exit status of a run with the given --fail-on
+// policy. Files that could not be hashed or checked outrank failed checks.
+func (s *Summary) exitCode(policy string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case policy == failOnNone:
+		return exitOK
+	case s.Errors > 0:
+		return exitError
+	case s.Failed > 0 && policy == failOnFailure:
+		return exitCheckFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons audit mode checks a file instead of replaying its stored result,
+// reported in Result.Reason.
+const (
+	reasonNew         = "new"
+	reasonChanged     = "changed"
+	reasonNewCheck    = "new-check"
+	reasonExpired     = "expired"
+	reasonRerunFailed = "rerun-failed"
+	reasonReverify    = "reverify"
+)
+
+// parseAge parses a --max-age duration. Besides the units of
+// time.ParseDuration it accepts whole days, e.g. "30d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days '%s'", days)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// expired reports whether entry was checked longer than --max-age ago.
+// Entries without a timestamp have an unknown age and count as expired.
+func expired(cfg Config, entry AuditEntry) bool {
+	return cfg.maxAge > 0 && time.Since(entry.CheckedAt) > cfg.maxAge
+}
+
+// recheckReason returns why audit mode checks a file, or "" if its stored
+// result is replayed. Expired entries have already been marked as changed.
+func recheckReason(cfg Config, result *Result, stored AuditEntry) string {
+	switch {
+	case !result.Audited:
+		return reasonNew
+	case result.Changed && result.Hash == stored.Hash:
+		return reasonExpired
+	case result.Changed:
+		return reasonChanged
+	case checkChanged(result, stored):
+		return reasonNewCheck
+	case cfg.rerunFailed && stored.ExitCode != 0:
+		return reasonRerunFailed
+	case cfg.reverifyFraction > 0 && rand.Float64() < cfg.reverifyFraction:
+		return reasonReverify
+	}
+	return ""
+}
+
+// checkTime returns the time stored for a check that runs now.
+func checkTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
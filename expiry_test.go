@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"xd", 0, true},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseAge(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAge(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAge(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		checkedAt time.Time
+		want      bool
+	}{
+		{"no max age", 0, now.Add(-1000 * time.Hour), false},
+		{"recent", time.Hour, now.Add(-time.Minute), false},
+		{"old", time.Hour, now.Add(-2 * time.Hour), true},
+		{"no timestamp", time.Hour, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{maxAge: tt.maxAge}
+			if got := expired(cfg, AuditEntry{CheckedAt: tt.checkedAt}); got != tt.want {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFileRecheckReason(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name       string
+		entry      *AuditEntry
+		maxAge     time.Duration
+		fraction   float64
+		rerun      bool
+		wantReason string
+		wantCached bool
+	}{
+		{"new file", nil, 0, 0, false, reasonNew, false},
+		{"changed", &AuditEntry{Hash: "old", CheckedAt: recent}, 0, 0, false, reasonChanged, false},
+		{"unchanged", &AuditEntry{Hash: hash, CheckedAt: recent}, 24 * time.Hour, 0, false, "", true},
+		{"new check", &AuditEntry{Hash: hash, CheckedAt: recent, Check: "0123456789abcdef"}, 0, 0, false, reasonNewCheck, false},
+		{"expired", &AuditEntry{Hash: hash, CheckedAt: old}, 24 * time.Hour, 0, false, reasonExpired, false},
+		{"no timestamp", &AuditEntry{Hash: hash}, 24 * time.Hour, 0, false, reasonExpired, false},
+		{"rerun failed", &AuditEntry{Hash: hash, ExitCode: 1, CheckedAt: recent}, 0, 0, true, reasonRerunFailed, false},
+		{"reverify all", &AuditEntry{Hash: hash, CheckedAt: recent}, 0, 1, false, reasonReverify, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditMap := map[string]AuditEntry{}
+			if tt.entry != nil {
+				tt.entry.Filename = file
+				auditMap[file] = *tt.entry
+			}
+
+			cfg := Config{command: "true", audit: true, quiet: true, maxAge: tt.maxAge, reverifyFraction: tt.fraction, rerunFailed: tt.rerun}
+			result := processFile(file, cfg, auditMap)
+			if result == nil {
+				t.Fatal("expected a result")
+			}
+			if result.Reason != tt.wantReason {
+				t.Errorf("expected reason %q, got %q", tt.wantReason, result.Reason)
+			}
+			if result.Cached != tt.wantCached {
+				t.Errorf("expected cached %v, got %v", tt.wantCached, result.Cached)
+			}
+			if !tt.wantCached && result.checkedAt.IsZero() {
+				t.Error("expected a checked file to get a check time")
+			}
+		})
+	}
+}
+
+func TestProcessFileExpiredIsNotTrusted(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "a.txt")
+	copy := filepath.Join(tempDir, "b.txt")
+	for _, file := range []string{source, copy} {
+		if err := os.WriteFile(file, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{command: "true", trustContent: true, quiet: true, maxAge: time.Hour}
+	check := checkID(rulesFor(cfg, copy), cfg)
+	auditMap := map[string]AuditEntry{
+		source: {Filename: source, Hash: hash, Check: check, CheckedAt: time.Now().Add(-2 * time.Hour)},
+	}
+	cfg.trusted = newTrustIndex(cfg, auditMap)
+
+	result := processFile(copy, cfg, auditMap)
+	if result == nil || result.TrustedFrom != "" {
+		t.Errorf("expected expired entry not to be trusted, got %+v", result)
+	}
+}
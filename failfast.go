@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// halter stops a run with --fail-fast once its outcome makes ghc exit
+// non-zero: no new files are dispatched and running checks are killed. The
+// results finished so far are still written and merged.
+type halter struct {
+	once    sync.Once
+	halting chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// newHalter returns a halter whose checks also stop when parent is done.
+func newHalter(parent context.Context) *halter {
+	ctx, cancel := context.WithCancel(parent)
+	return &halter{halting: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+// halt stops the run. It is safe to call more than once.
+func (h *halter) halt() {
+	h.once.Do(func() {
+		close(h.halting)
+		h.cancel()
+	})
+}
+
+// halted reports whether the run was stopped. A nil halter never stops.
+func (h *halter) halted() bool {
+	if h == nil {
+		return false
+	}
+	select {
+	case <-h.halting:
+		return true
+	default:
+		return false
+	}
+}
+
+// done returns a channel closed when the run is stopped.
+func (h *halter) done() <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	return h.halting
+}
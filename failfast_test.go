@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHalter(t *testing.T) {
+	var none *halter
+	if none.halted() || none.done() != nil {
+		t.Error("expected a nil halter never to stop")
+	}
+
+	h := newHalter(t.Context())
+	if h.halted() {
+		t.Fatal("expected no stop before halt")
+	}
+	h.halt()
+	h.halt()
+	if !h.halted() || h.ctx.Err() == nil {
+		t.Error("expected halt to stop dispatch and running checks")
+	}
+}
+
+func TestProcessFilesFailFast(t *testing.T) {
+	tempDir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		file := filepath.Join(tempDir, name)
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	tests := []struct {
+		name          string
+		failOn        string
+		wantProcessed int
+	}{
+		{"failure", failOnFailure, 1},
+		{"error policy ignores failed checks", failOnError, 4},
+		{"none", failOnNone, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{command: "false", workers: 1, quiet: true, failFast: true, failOn: tt.failOn}
+			summary := processFiles(files, cfg, nil, io.Discard)
+			if summary.Processed != tt.wantProcessed || summary.Failed != tt.wantProcessed {
+				t.Errorf("expected %d files processed and failed, got %d and %d", tt.wantProcessed, summary.Processed, summary.Failed)
+			}
+		})
+	}
+}
+
+func TestProcessFilesFailFastKillsRunningChecks(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	slow := filepath.Join(tempDir, "slow.txt")
+	failing := filepath.Join(tempDir, "failing.txt")
+	for _, file := range []string{slow, failing} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The sleep is a child of the shell, which has to be killed with it
+	cfg := Config{
+		command:    "case $FILE in *slow*) sleep 10; true;; *) exit 1;; esac",
+		update:     true,
+		hashesFile: hashesFile,
+		workers:    2,
+		quiet:      true,
+		failFast:   true,
+		failOn:     failOnFailure,
+	}
+	start := time.Now()
+	summary := processFiles([]string{slow, failing}, cfg, nil, io.Discard)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the running check to be killed, took %s", elapsed)
+	}
+	if summary.Failed != 1 || summary.Errors != 0 {
+		t.Errorf("expected 1 failed check and no errors, got %d and %d", summary.Failed, summary.Errors)
+	}
+
+	// The finished failure is saved; the killed check is left for the next run
+	mergeHashFiles(hashesFile)
+	loaded := loadAuditFile(hashesFile)
+	if _, ok := loaded[slow]; ok || loaded[failing].ExitCode != 1 {
+		t.Errorf("expected only the failed check to be saved, got %+v", loaded)
+	}
+}
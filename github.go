@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// githubEncoder prints GitHub Actions workflow commands annotating the
+// problems of failed checks, and adds a summary of the run to the job's
+// step summary when $GITHUB_STEP_SUMMARY is set.
+type githubEncoder struct {
+	cfg     Config
+	output  io.Writer
+	pattern *regexp.Regexp
+	counts  map[string]int
+	failed  int
+}
+
+func newGitHubEncoder(cfg Config, output io.Writer) *githubEncoder {
+	return &githubEncoder{cfg: cfg, output: output, pattern: cfg.diagnosticPattern, counts: make(map[string]int)}
+}
+
+func (e *githubEncoder) encode(result *Result) error {
+	e.counts[classify(result)]++
+	if !result.failed {
+		return nil
+	}
+	e.failed++
+
+	title := checkName(e.cfg, result)
+	for _, d := range diagnostics(result, e.pattern) {
+		properties := "file=" + githubProperty(relativePath(d.file))
+		if d.line > 0 {
+			properties += fmt.Sprintf(",line=%d", d.line)
+		}
+		if d.column > 0 {
+			properties += fmt.Sprintf(",col=%d", d.column)
+		}
+		properties += ",title=" + githubProperty(title)
+		if _, err := fmt.Fprintf(e.output, "::error %s::%s\n", properties, githubData(d.message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close appends the counts of the run to the step summary.
+func (e *githubEncoder) close() error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("### ghc results\n\n")
+	b.WriteString("| Files | Count |\n")
+	b.WriteString("|-------|------:|\n")
+	fmt.Fprintf(&b, "| New | %d |\n", e.counts[statusNew])
+	fmt.Fprintf(&b, "| Changed | %d |\n", e.counts[statusChanged])
+	fmt.Fprintf(&b, "| Unchanged | %d |\n", e.counts[statusUnchanged])
+	if e.counts[statusRenamed] > 0 {
+		fmt.Fprintf(&b, "| Renamed | %d |\n", e.counts[statusRenamed])
+	}
+	if e.counts[statusTrusted] > 0 {
+		fmt.Fprintf(&b, "| Trusted | %d |\n", e.counts[statusTrusted])
+	}
+	fmt.Fprintf(&b, "| Failed | %d |\n\n", e.failed)
+	return appendFile(path, b.String())
+}
+
+// appendFile appends s to the file at path, creating it if needed.
+func appendFile(path, s string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// githubData escapes the message of a workflow command.
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a workflow command.
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
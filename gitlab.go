@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// gitlabIssue is an issue of a GitLab Code Quality report.
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+// gitlabEncoder collects the problems of failed checks into a GitLab Code
+// Quality report, written as a JSON array when the run is done.
+type gitlabEncoder struct {
+	cfg     Config
+	output  io.Writer
+	pattern *regexp.Regexp
+	issues  []gitlabIssue
+}
+
+func newGitLabEncoder(cfg Config, output io.Writer) *gitlabEncoder {
+	return &gitlabEncoder{cfg: cfg, output: output, pattern: cfg.diagnosticPattern, issues: []gitlabIssue{}}
+}
+
+func (e *gitlabEncoder) encode(result *Result) error {
+	if !result.failed {
+		return nil
+	}
+
+	check := checkName(e.cfg, result)
+	for _, d := range diagnostics(result, e.pattern) {
+		issue := gitlabIssue{
+			Description: d.message,
+			CheckName:   check,
+			Severity:    "major",
+			Location:    gitlabLocation{Path: relativePath(d.file), Lines: gitlabLines{Begin: max(d.line, 1)}},
+		}
+		// The fingerprint identifies the issue across pipelines
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%d\x00%s", check, issue.Location.Path, d.line, d.message))
+		issue.Fingerprint = hex.EncodeToString(sum[:])
+		e.issues = append(e.issues, issue)
+	}
+	return nil
+}
+
+// close writes the report, with issues sorted by location.
+func (e *gitlabEncoder) close() error {
+	sort.SliceStable(e.issues, func(i, j int) bool {
+		a, b := e.issues[i].Location, e.issues[j].Location
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Lines.Begin < b.Lines.Begin
+	})
+	encoder := json.NewEncoder(e.output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e.issues)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
@@ -8,22 +9,34 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 )
 
+// timeoutExitCode is reported for commands stopped by --timeout, matching
+// the exit status of timeout(1).
+const timeoutExitCode = 124
+
 var bufferPool = sync.Pool{
 	New: func() any {
 		return make([]byte, 64*1024) // 64KB buffer
 	},
 }
 
-func processFile(filename string, cfg Config, auditMap map[string]string) *Result {
-	hash, err := hashFile(filename)
+func processFile(filename string, cfg Config, auditMap map[string]AuditEntry) *Result {
+	hashStart := time.Now()
+	hash, size, err := hashFileSize(filename)
 	if err != nil {
+		// A status run reports files that are only left in the hashes file
+		if _, known := auditMap[filename]; cfg.dryRun && known && os.IsNotExist(err) {
+			return &Result{Filename: filename, Audited: true, Status: statusMissing}
+		}
 		if !cfg.quiet {
 			logError("Error hashing %s: %v\n", filename, err)
 		}
+		cfg.summary.addError()
 		return nil
 	}
+	cfg.summary.addHashed(size, time.Since(hashStart))
 
 	result := &Result{
 		Filename: filename,
@@ -31,36 +44,153 @@ func processFile(filename string, cfg Config, auditMap map[string]string) *Resul
 	}
 
 	// Check audit if available
+	var stored AuditEntry
 	if auditMap != nil {
-		expectedHash, exists := auditMap[filename]
+		expected, exists := auditMap[filename]
 		if exists {
 			result.Audited = true
-			result.Changed = hash != expectedHash
+			result.Changed = hash != expected.Hash
+			stored = expected
+		} else if cfg.renames != nil {
+			// A renamed file keeps the verification of its old path
+			if from, ok := cfg.renames.claim(hash); ok {
+				result.Audited = true
+				result.RenamedFrom = from.Filename
+				stored = from
+			}
 		}
 	}
 
-	// Run command if specified
-	// In audit mode, only run if file changed
-	shouldRunCommand := cfg.command != "" && (!cfg.audit || result.Changed)
+	// Choose the checks to run from the rules matching the file
+	rules := rulesFor(cfg, filename)
+	if rules == nil {
+		return nil
+	}
+	result.check = checkID(rules, cfg)
 
-	if shouldRunCommand {
-		result.ExitCode = runCommand(cfg, filename)
+	// With --max-age, an entry checked too long ago counts as changed
+	if result.Audited && !result.Changed && expired(cfg, stored) {
+		result.Changed = true
+	}
 
-		// Handle -1 exit code (command execution error) specially
-		if result.ExitCode == -1 && cfg.filterOnCodes && !cfg.errorCodes[-1] {
-			if !cfg.quiet {
-				logError("Command failed to run with exit code -1 for %s. If expected, add -1 to the error exit codes with --error-exit-codes\n", filename)
+	// With --trust-content, content that passed the same checks under any
+	// path is not checked again
+	if cfg.trusted != nil {
+		if from, ok := cfg.trusted.lookup(hash, result.check); ok {
+			result.TrustedFrom = from
+		}
+	}
+
+	// A status run only classifies the file
+	if cfg.dryRun {
+		result.Status = classify(result)
+		return result
+	}
+	cfg.summary.addStatus(classify(result))
+
+	// In audit mode, only run if the file is new or changed, or needs to be
+	// checked again for another reason. Otherwise it replays its stored
+	// result, including a cached failure.
+	if cfg.audit {
+		result.Reason = recheckReason(cfg, result, stored)
+		switch result.Reason {
+		case "":
+			result.Rule = stored.Rule
+			result.check = stored.Check
+			result.checkedAt = stored.CheckedAt
+			result.ExitCode = stored.ExitCode
+			result.output = cachedOutput(cfg, stored)
+			result.Cached = true
+			cfg.summary.addSkipped()
+
+			// The filters of the first matching rule apply to a replayed result
+			decisive := rules[0].apply(cfg)
+			result.failed = !succeeded(decisive, result.ExitCode)
+			cfg.summary.addCheck(decisive, result.ExitCode)
+			result = filterResult(result, decisive)
+			if result != nil {
+				replayOutput(result.output)
 			}
+			return result
+		case reasonRerunFailed, reasonReverify:
+			// The file's own entry must not vouch for it
+			result.TrustedFrom = ""
+		}
+	}
+	if result.TrustedFrom != "" {
+		result.checkedAt = checkTime()
+		if source, ok := auditMap[result.TrustedFrom]; ok && !source.CheckedAt.IsZero() {
+			result.checkedAt = source.CheckedAt
+		}
+		cfg.summary.addCheck(Config{}, 0)
+		cfg.summary.addSkipped()
+		return result
+	}
+
+	// Run each check; the first failing one decides the exit code
+	var names []string
+	var decisive Config
+	var output limitedBuffer
+	ran := 0
+	start := time.Now()
+	for _, rule := range rules {
+		ruleCfg := rule.apply(cfg)
+		if ruleCfg.command == "" {
+			continue
+		}
+
+		exitCode := runCommand(ruleCfg, filename, &output)
+		if exitCode == -1 && cfg.halt.halted() {
+			// Killed by --fail-fast because another file failed; the file
+			// is left for the next run
 			return nil
 		}
+		if ran == 0 || (result.ExitCode == 0 && exitCode != 0) {
+			result.ExitCode = exitCode
+			decisive = ruleCfg
+		}
+		if rule.Name != "" {
+			names = append(names, rule.Name)
+		}
+		ran++
+	}
 
-		// Filter based on success/error codes
-		if cfg.filterOnCodes {
-			isSuccess := cfg.successCodes[result.ExitCode]
-			isError := cfg.errorCodes[result.ExitCode]
-			if !isSuccess && !isError {
-				return nil
-			}
+	if ran == 0 {
+		return result
+	}
+	result.Rule = strings.Join(names, ",")
+	result.checkedAt = checkTime()
+	result.duration = time.Since(start)
+	cfg.summary.addCheckTime(filename, result.duration)
+	if result.ExitCode == 0 && cfg.trusted != nil {
+		cfg.trusted.add(hash, result.check, filename)
+	}
+
+	// The output is cached with the result, to be replayed when it's skipped
+	result.output = output.String()
+	result.failed = !succeeded(decisive, result.ExitCode)
+	cfg.summary.addCheck(decisive, result.ExitCode)
+
+	return filterResult(result, decisive)
+}
+
+// filterResult applies the exit-code filters of the check that decided the
+// result, returning nil if the result is left out.
+func filterResult(result *Result, decisive Config) *Result {
+	// Handle -1 exit code (command execution error) specially
+	if result.ExitCode == -1 && decisive.filterOnCodes && !decisive.errorCodes[-1] {
+		if !decisive.quiet {
+			logError("Command failed to run with exit code -1 for %s. If expected, add -1 to the error exit codes with --error-exit-codes\n", result.Filename)
+		}
+		return nil
+	}
+
+	// Filter based on success/error codes
+	if decisive.filterOnCodes {
+		isSuccess := decisive.successCodes[result.ExitCode]
+		isError := decisive.errorCodes[result.ExitCode]
+		if !isSuccess && !isError {
+			return nil
 		}
 	}
 
@@ -68,9 +198,15 @@ func processFile(filename string, cfg Config, auditMap map[string]string) *Resul
 }
 
 func hashFile(filename string) (string, error) {
+	hash, _, err := hashFileSize(filename)
+	return hash, err
+}
+
+// hashFileSize returns the SHA256 digest of filename and its size in bytes.
+func hashFileSize(filename string) (string, int64, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer f.Close()
 
@@ -81,14 +217,26 @@ func hashFile(filename string) (string, error) {
 	defer bufferPool.Put(buf)
 
 	// Use CopyBuffer for efficient streaming with reused buffer
-	if _, err := io.CopyBuffer(h, f, buf); err != nil {
-		return "", err
+	size, err := io.CopyBuffer(h, f, buf)
+	if err != nil {
+		return "", 0, err
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return hex.EncodeToString(h.Sum(nil)), size, nil
 }
 
-func runCommand(cfg Config, filename string) int {
+// isSHA256 reports whether hash is a hex-encoded SHA256 digest.
+func isSHA256(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// runCommand runs the check command on filename and returns its exit code.
+// The command's output goes to stderr and, if output is not nil, to output.
+func runCommand(cfg Config, filename string, output io.Writer) int {
 	// Replace $FILE placeholder with filename, or append filename if no placeholder
 	command := cfg.command
 	if strings.Contains(command, "$FILE") {
@@ -109,15 +257,45 @@ func runCommand(cfg Config, filename string) int {
 		}
 	}
 
-	cmd := exec.Command("sh", "-c", command)
+	ctx := cfg.interrupt.context()
+	if cfg.halt != nil {
+		ctx = cfg.halt.ctx
+	}
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	// Cancelling the check kills every process of the command, not just the
+	// shell: its children would keep the output pipe open and Wait waiting
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	killGroupOnCancel(cmd)
+	cmd.WaitDelay = time.Second
 	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
+	if output != nil {
+		cmd.Stdout = io.MultiWriter(os.Stderr, output)
+		cmd.Stderr = cmd.Stdout
+	}
 
 	err := cmd.Run()
 	if err == nil {
 		return 0
 	}
 
+	if ctx.Err() == context.DeadlineExceeded {
+		if !cfg.quiet {
+			logError("Command timed out after %s for %s\n", cfg.timeout, filename)
+		}
+		return timeoutExitCode
+	}
+	if ctx.Err() == context.Canceled {
+		// Stopped by a second interrupt or --fail-fast; like a command that
+		// could not run, the result isn't cached
+		return -1
+	}
+
 	exitErr, ok := err.(*exec.ExitError)
 	if !ok {
 		if !cfg.quiet {
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"os"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestHashFile(t *testing.T) {
@@ -119,7 +121,7 @@ func TestProcessFile(t *testing.T) {
 	tests := []struct {
 		name      string
 		cfg       Config
-		auditMap  map[string]string
+		auditMap  map[string]AuditEntry
 		expectNil bool
 	}{
 		{
@@ -143,8 +145,8 @@ func TestProcessFile(t *testing.T) {
 			cfg: Config{
 				command: "true",
 			},
-			auditMap: map[string]string{
-				tmpfile.Name(): "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			auditMap: map[string]AuditEntry{
+				tmpfile.Name(): {Filename: tmpfile.Name(), Hash: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
 			},
 			expectNil: false,
 		},
@@ -212,7 +214,7 @@ func TestRunCommand(t *testing.T) {
 				command: tt.command,
 				quiet:   false,
 			}
-			code := runCommand(cfg, tmpfile.Name())
+			code := runCommand(cfg, tmpfile.Name(), nil)
 			if code != tt.expectedCode {
 				t.Errorf("expected exit code %d, got %d", tt.expectedCode, code)
 			}
@@ -239,7 +241,7 @@ func TestRunCommandWithFILEPlaceholder(t *testing.T) {
 		quiet:   false,
 	}
 
-	code := runCommand(cfg, tmpfile.Name())
+	code := runCommand(cfg, tmpfile.Name(), nil)
 	if code != 0 {
 		t.Errorf("expected exit code 0, got %d", code)
 	}
@@ -269,7 +271,7 @@ func TestRunCommandWithFILEPlaceholderAndQuotes(t *testing.T) {
 		quiet:   false,
 	}
 
-	code := runCommand(cfg, tmpfile.Name())
+	code := runCommand(cfg, tmpfile.Name(), nil)
 	if code != 0 {
 		t.Errorf("expected exit code 0, got %d", code)
 	}
@@ -311,7 +313,7 @@ func TestRunCommandStandaloneCommands(t *testing.T) {
 				command: tt.command,
 				quiet:   false,
 			}
-			code := runCommand(cfg, tmpfile.Name())
+			code := runCommand(cfg, tmpfile.Name(), nil)
 			if code != tt.expectedCode {
 				t.Errorf("expected exit code %d, got %d", tt.expectedCode, code)
 			}
@@ -530,3 +532,46 @@ func TestProcessFile_ErrorExitCodeMinus1(t *testing.T) {
 		})
 	}
 }
+
+func TestRunCommandTimeout(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	cfg := Config{
+		command: "sleep 2; true $FILE",
+		timeout: 100 * time.Millisecond,
+		quiet:   true,
+	}
+
+	start := time.Now()
+	code := runCommand(cfg, tmpfile.Name(), nil)
+	if code != timeoutExitCode {
+		t.Errorf("expected exit code %d, got %d", timeoutExitCode, code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("command was not stopped by timeout, took %s", elapsed)
+	}
+}
+
+func TestRunCommandTimeoutKillsChildren(t *testing.T) {
+	// The sleep holds the captured output open after the shell is killed
+	cfg := Config{
+		command: "sleep 10; true",
+		timeout: 100 * time.Millisecond,
+		quiet:   true,
+	}
+
+	start := time.Now()
+	var output bytes.Buffer
+	code := runCommand(cfg, "file.txt", &output)
+	if code != timeoutExitCode {
+		t.Errorf("expected exit code %d, got %d", timeoutExitCode, code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("command was not stopped by timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// formatBinary is the indexed binary hashes file format written by
+// db convert. Hashes files in it stay binary when they are updated.
+const formatBinary = "binary"
+
+// The binary hashes file holds a header, the entries as fixed-width records
+// sorted by filename and a table of the strings they refer to. All integers
+// are little-endian.
+//
+//	header  magic [8]byte, version uint32, record size uint32,
+//	        record count uint64, string table size uint64,
+//	        CRC-32C of records and string table uint32,
+//	        CRC-32C of the preceding header fields uint32
+//	record  hash [32]byte, filename, rule, check and output blob as
+//	        (offset, length uint32) into the string table, checked at
+//	        (Unix seconds, 0 if unknown) int64, exit code int32, reserved uint32
+//
+// Entries updated since the file was written are appended to a delta log next
+// to it, see deltaLogPath.
+const (
+	hashDBMagic      = "GHCHDB\x00\x00"
+	hashDBVersion    = 1
+	hashDBHeaderSize = 40
+	hashDBRecordSize = 80
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// hashDB is an open binary hashes file. Its records are memory-mapped where
+// the platform allows, so single entries can be looked up without decoding
+// the whole file.
+type hashDB struct {
+	data    []byte
+	records []byte
+	strings []byte
+	count   int
+	delta   map[string]journalRecord
+	release func() error
+}
+
+// deltaLogPath returns the path of the delta log of a binary hashes file.
+func deltaLogPath(filename string) string {
+	return filename + ".log"
+}
+
+// isHashDB reports whether header starts like a binary hashes file.
+func isHashDB(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(hashDBMagic))
+}
+
+// openHashDB opens the binary hashes file filename and replays its delta log.
+// The header and data checksums are verified.
+func openHashDB(filename string) (*hashDB, error) {
+	data, release, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	db := &hashDB{data: data, release: release}
+	if err := db.parse(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if db.delta, err = readDeltaLog(deltaLogPath(filename)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// parse validates the header and locates the records and string table.
+func (db *hashDB) parse() error {
+	data := db.data
+	if len(data) < hashDBHeaderSize || !isHashDB(data) {
+		return errors.New("not a binary hashes file")
+	}
+	le := binary.LittleEndian
+	if crc32.Checksum(data[:36], crcTable) != le.Uint32(data[36:]) {
+		return errors.New("header checksum mismatch")
+	}
+	if version := le.Uint32(data[8:]); version != hashDBVersion {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	if size := le.Uint32(data[12:]); size != hashDBRecordSize {
+		return fmt.Errorf("unsupported record size %d", size)
+	}
+
+	count := le.Uint64(data[16:])
+	stringsSize := le.Uint64(data[24:])
+	body := uint64(len(data) - hashDBHeaderSize)
+	if count > body/hashDBRecordSize || stringsSize != body-count*hashDBRecordSize {
+		return errors.New("truncated or corrupt file")
+	}
+	if crc32.Checksum(data[hashDBHeaderSize:], crcTable) != le.Uint32(data[32:]) {
+		return errors.New("data checksum mismatch")
+	}
+
+	db.count = int(count)
+	db.records = data[hashDBHeaderSize : hashDBHeaderSize+count*hashDBRecordSize]
+	db.strings = data[hashDBHeaderSize+count*hashDBRecordSize:]
+	return nil
+}
+
+// Close unmaps the file. Entries returned earlier stay valid.
+func (db *hashDB) Close() error {
+	if db.release == nil {
+		return nil
+	}
+	release := db.release
+	db.release = nil
+	return release()
+}
+
+// strBytes returns the string table entry referred to at record offset off.
+func (db *hashDB) strBytes(record []byte, off int) []byte {
+	start := binary.LittleEndian.Uint32(record[off:])
+	length := binary.LittleEndian.Uint32(record[off+4:])
+	if uint64(start)+uint64(length) > uint64(len(db.strings)) {
+		return nil
+	}
+	return db.strings[start : start+length]
+}
+
+// str copies the string table entry referred to at record offset off out of
+// the mapped file.
+func (db *hashDB) str(record []byte, off int) string {
+	return string(db.strBytes(record, off))
+}
+
+// record returns the i-th record.
+func (db *hashDB) record(i int) []byte {
+	return db.records[i*hashDBRecordSize : (i+1)*hashDBRecordSize]
+}
+
+// entry decodes the i-th record.
+func (db *hashDB) entry(i int) AuditEntry {
+	record := db.record(i)
+	le := binary.LittleEndian
+	entry := AuditEntry{
+		Filename:   db.str(record, 32),
+		Hash:       hex.EncodeToString(record[:32]),
+		Rule:       db.str(record, 40),
+		Check:      db.str(record, 48),
+		OutputBlob: db.str(record, 56),
+		ExitCode:   int(int32(le.Uint32(record[72:]))),
+	}
+	if checkedAt := int64(le.Uint64(record[64:])); checkedAt != 0 {
+		entry.CheckedAt = time.Unix(checkedAt, 0).UTC()
+	}
+	return entry
+}
+
+// get looks up the entry for filename, by binary search over the records
+// unless the delta log has a newer one.
+func (db *hashDB) get(filename string) (AuditEntry, bool) {
+	if delta, ok := db.delta[filename]; ok {
+		return delta.AuditEntry, !delta.Deleted
+	}
+	i := sort.Search(db.count, func(i int) bool {
+		return string(db.strBytes(db.record(i), 32)) >= filename
+	})
+	if i < db.count && string(db.strBytes(db.record(i), 32)) == filename {
+		return db.entry(i), true
+	}
+	return AuditEntry{}, false
+}
+
+// entries decodes all entries, with the delta log applied.
+func (db *hashDB) entries() map[string]AuditEntry {
+	entries := make(map[string]AuditEntry, db.count+len(db.delta))
+	for i := range db.count {
+		entry := db.entry(i)
+		entries[entry.Filename] = entry
+	}
+	for name, delta := range db.delta {
+		if delta.Deleted {
+			delete(entries, name)
+		} else {
+			entries[name] = delta.AuditEntry
+		}
+	}
+	return entries
+}
+
+// readHashDB returns the entries of the binary hashes file filename.
+func readHashDB(filename string) (map[string]AuditEntry, error) {
+	db, err := openHashDB(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.entries(), nil
+}
+
+// writeHashDB replaces filename with a binary hashes file holding entries
+// and removes its delta log, which the new file includes.
+func writeHashDB(filename string, entries map[string]AuditEntry) error {
+	names := slices.Sorted(maps.Keys(entries))
+
+	// Strings repeat a lot (rules, checks, shared outputs), so each is stored once
+	var table bytes.Buffer
+	offsets := make(map[string]uint32)
+	ref := func(s string) ([]byte, error) {
+		off, ok := offsets[s]
+		if !ok {
+			if uint64(table.Len())+uint64(len(s)) > math.MaxUint32 {
+				return nil, errors.New("string table exceeds 4 GiB")
+			}
+			off = uint32(table.Len())
+			offsets[s] = off
+			table.WriteString(s)
+		}
+		return binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, off), uint32(len(s))), nil
+	}
+
+	records := make([]byte, 0, len(names)*hashDBRecordSize)
+	for _, name := range names {
+		entry := entries[name]
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(hash) != 32 {
+			return fmt.Errorf("hash of %s is not a SHA256 digest: %q", name, entry.Hash)
+		}
+		records = append(records, hash...)
+		for _, s := range []string{entry.Filename, entry.Rule, entry.Check, entry.OutputBlob} {
+			b, err := ref(s)
+			if err != nil {
+				return err
+			}
+			records = append(records, b...)
+		}
+		var checkedAt int64
+		if !entry.CheckedAt.IsZero() {
+			checkedAt = entry.CheckedAt.Unix()
+		}
+		records = binary.LittleEndian.AppendUint64(records, uint64(checkedAt))
+		records = binary.LittleEndian.AppendUint32(records, uint32(int32(entry.ExitCode)))
+		records = binary.LittleEndian.AppendUint32(records, 0)
+	}
+
+	crc := crc32.Update(crc32.Checksum(records, crcTable), crcTable, table.Bytes())
+	header := []byte(hashDBMagic)
+	header = binary.LittleEndian.AppendUint32(header, hashDBVersion)
+	header = binary.LittleEndian.AppendUint32(header, hashDBRecordSize)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(names)))
+	header = binary.LittleEndian.AppendUint64(header, uint64(table.Len()))
+	header = binary.LittleEndian.AppendUint32(header, crc)
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, crcTable))
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, part := range [][]byte{header, records, table.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	if err := os.Remove(deltaLogPath(filename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readDeltaLog reads the delta log at path, keeping the last record for each
+// file. A missing log is empty.
+func readDeltaLog(path string) (map[string]journalRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return make(map[string]journalRecord), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	delta, err := readJournal(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return delta, nil
+}
+
+// updateHashDB applies updated and removed entries to the binary hashes file
+// filename by appending the ones that change it to its delta log. Once the
+// log has grown to half the size of the file, it is compacted into a new file.
+func updateHashDB(filename string, updated []AuditEntry, removed []string) error {
+	db, err := openHashDB(filename)
+	if err != nil {
+		return err
+	}
+	var records []journalRecord
+	for _, name := range removed {
+		if _, ok := db.get(name); ok {
+			records = append(records, journalRecord{AuditEntry: AuditEntry{Filename: name}, Deleted: true})
+		}
+	}
+	for _, entry := range updated {
+		// Replayed results of unchanged files leave their entries as they were
+		if existing, ok := db.get(entry.Filename); !ok || !existing.equal(entry) {
+			records = append(records, journalRecord{AuditEntry: entry})
+		}
+	}
+	db.Close()
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := appendJournal(deltaLogPath(filename), filename, records); err != nil {
+		return err
+	}
+	return compactHashDBIfLarge(filename)
+}
+
+// compactHashDBIfLarge compacts the binary hashes file filename once its
+// delta log has grown to half the size of the file.
+func compactHashDBIfLarge(filename string) error {
+	logInfo, err := os.Stat(deltaLogPath(filename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dbInfo, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if logInfo.Size() <= dbInfo.Size()/2 {
+		return nil
+	}
+	_, err = compactHashesFile(filename)
+	return err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashDBRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	dbFile := filepath.Join(tempDir, "hashes.db")
+	checkedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := map[string]AuditEntry{
+		"b.txt": {Filename: "b.txt", Hash: testHash2, ExitCode: -3, Rule: "lint", Check: "0123456789abcdef", CheckedAt: checkedAt},
+		"a.txt": {Filename: "a.txt", Hash: testHash1, OutputBlob: testHash2, Rule: "lint"},
+	}
+	if err := writeHashDB(dbFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if format := detectFormat(dbFile); format != formatBinary {
+		t.Errorf("expected binary format to be detected, got %q", format)
+	}
+	loaded := loadAuditFile(dbFile)
+	if len(loaded) != 2 || loaded["a.txt"] != entries["a.txt"] || loaded["b.txt"] != entries["b.txt"] {
+		t.Errorf("expected entries to round-trip, got %+v", loaded)
+	}
+
+	db, err := openHashDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if entry, ok := db.get(name); !ok || entry != entries[name] {
+			t.Errorf("get(%q) = %+v, %v", name, entry, ok)
+		}
+	}
+	for _, name := range []string{"", "0.txt", "ab.txt", "c.txt"} {
+		if _, ok := db.get(name); ok {
+			t.Errorf("expected no entry for %q", name)
+		}
+	}
+}
+
+func TestHashDBEmpty(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "hashes.db")
+	if err := writeHashDB(dbFile, map[string]AuditEntry{}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHashDB(dbFile)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected empty database, got %v, %v", entries, err)
+	}
+}
+
+func TestWriteHashDBRejectsInvalidHash(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "hashes.db")
+	err := writeHashDB(dbFile, map[string]AuditEntry{"a.txt": {Filename: "a.txt", Hash: "hash1"}})
+	if err == nil || !strings.Contains(err.Error(), "not a SHA256 digest") {
+		t.Errorf("expected invalid hash to be rejected, got %v", err)
+	}
+}
+
+func TestOpenHashDBCorrupt(t *testing.T) {
+	tempDir := t.TempDir()
+	dbFile := filepath.Join(tempDir, "hashes.db")
+	if err := writeHashDB(dbFile, map[string]AuditEntry{"a.txt": {Filename: "a.txt", Hash: testHash1}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+		wantErr string
+	}{
+		{"header", func(b []byte) []byte { b[16]++; return b }, "header checksum"},
+		{"data", func(b []byte) []byte { b[len(b)-1]++; return b }, "data checksum"},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-3] }, "truncated"},
+		{"magic", func(b []byte) []byte { b[0] = 'X'; return b }, "not a binary hashes file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.name+".db")
+			if err := os.WriteFile(path, tt.corrupt(bytes.Clone(data)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := openHashDB(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected %q error, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUpdateHashDB(t *testing.T) {
+	tempDir := t.TempDir()
+	dbFile := filepath.Join(tempDir, "hashes.db")
+	entries := make(map[string]AuditEntry)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt", "f.txt"} {
+		entries[name] = AuditEntry{Filename: name, Hash: testHash1}
+	}
+	if err := writeHashDB(dbFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	// A small update only goes to the delta log
+	if err := updateHashDB(dbFile, []AuditEntry{{Filename: "a.txt", Hash: testHash2}}, []string{"b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(deltaLogPath(dbFile)); err != nil {
+		t.Fatalf("expected delta log, got %v", err)
+	}
+	loaded := loadAuditFile(dbFile)
+	if loaded["a.txt"].Hash != testHash2 {
+		t.Error("expected updated entry from delta log")
+	}
+	if _, ok := loaded["b.txt"]; ok {
+		t.Error("expected removed entry to be gone")
+	}
+	if len(loaded) != 5 {
+		t.Errorf("expected 5 entries, got %d", len(loaded))
+	}
+
+	// Once the log outgrows half the file, it is compacted
+	var updated []AuditEntry
+	for i := range 20 {
+		name := filepath.Join("new", strings.Repeat("x", i)+".txt")
+		updated = append(updated, AuditEntry{Filename: name, Hash: testHash1})
+	}
+	if err := updateHashDB(dbFile, updated, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(deltaLogPath(dbFile)); !os.IsNotExist(err) {
+		t.Errorf("expected delta log to be compacted, got %v", err)
+	}
+	if loaded := loadAuditFile(dbFile); len(loaded) != 25 || loaded["a.txt"].Hash != testHash2 {
+		t.Errorf("expected compacted database with 25 entries, got %d", len(loaded))
+	}
+}
+
+func TestMergeHashFilesBinary(t *testing.T) {
+	tempDir := t.TempDir()
+	dbFile := filepath.Join(tempDir, "hashes.db")
+	if err := writeHashDB(dbFile, map[string]AuditEntry{
+		"old.txt":  {Filename: "old.txt", Hash: testHash1},
+		"keep.txt": {Filename: "keep.txt", Hash: testHash1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONLFile(dbFile+".new", map[string]AuditEntry{
+		"new.txt": {Filename: "new.txt", Hash: testHash1, RenamedFrom: "old.txt"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mergeHashFiles(dbFile)
+
+	if detectFormat(dbFile) != formatBinary {
+		t.Error("expected hashes file to stay binary")
+	}
+	loaded := loadAuditFile(dbFile)
+	if _, ok := loaded["old.txt"]; ok || len(loaded) != 2 {
+		t.Errorf("expected rename to move the entry, got %+v", loaded)
+	}
+	if loaded["new.txt"].RenamedFrom != "" {
+		t.Error("expected renamed_from to be cleared")
+	}
+}
+
+func TestRunDBConvert(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	entries := map[string]AuditEntry{
+		"a.txt": {Filename: "a.txt", Hash: testHash1},
+		"b.txt": {Filename: "b.txt", Hash: testHash2, ExitCode: 1},
+	}
+	if err := writeAuditFile(hashesFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	convert := func(format string, args ...string) int {
+		cfg := Config{hashesFile: hashesFile, dbAction: "convert", format: format}
+		return runDB(cfg, args, io.Discard)
+	}
+
+	dbFile := filepath.Join(tempDir, "hashes.db")
+	if code := convert(formatBinary, dbFile); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if detectFormat(dbFile) != formatBinary || detectFormat(hashesFile) != formatJSONL {
+		t.Error("expected binary copy next to the JSONL file")
+	}
+
+	if code := convert(formatBinary); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if detectFormat(hashesFile) != formatBinary || len(loadAuditFile(hashesFile)) != 2 {
+		t.Error("expected hashes file to be converted in place")
+	}
+
+	// Lookups work on the binary file, and further writes keep it binary
+	var buf bytes.Buffer
+	if code := runDB(Config{hashesFile: hashesFile, dbAction: "get"}, []string{"b.txt"}, &buf); code != 0 || !strings.Contains(buf.String(), testHash2) {
+		t.Errorf("expected entry from binary file, got %d: %s", code, buf.String())
+	}
+	if code := runDB(Config{hashesFile: hashesFile, dbAction: "rm"}, []string{"a.txt"}, io.Discard); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if detectFormat(hashesFile) != formatBinary || len(loadAuditFile(hashesFile)) != 1 {
+		t.Error("expected entry to be removed from the binary file")
+	}
+	if code := runDB(Config{hashesFile: hashesFile, dbAction: "set"}, []string{"c.txt", "nothex"}, io.Discard); code != exitUsage {
+		t.Errorf("expected exit code %d for an invalid hash, got %d", exitUsage, code)
+	}
+
+	if code := convert(formatJSONL); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if detectFormat(hashesFile) != formatJSONL || loadAuditFile(hashesFile)["b.txt"] != entries["b.txt"] {
+		t.Error("expected hashes file to be converted back to JSONL")
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyRunsFile is the log of runs in the history directory.
+const historyRunsFile = "runs.jsonl"
+
+// A check time regressed when its latest run took slowerFactor times its
+// median of the runs before, and at least slowerMinMS longer.
+const (
+	slowerFactor = 1.5
+	slowerMinMS  = 10
+)
+
+// historyDir returns the directory of the run history kept with a hashes
+// file: the log of runs and a compressed file of the results of each.
+func historyDir(hashesFile string) string {
+	return hashesFile + ".history"
+}
+
+// HistoryRun is an entry in the log of runs.
+type HistoryRun struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Args        []string  `json:"args"`
+	Commit      string    `json:"commit,omitempty"`
+	Interrupted bool      `json:"interrupted,omitempty"`
+	Stats       *RunStats `json:"stats"`
+	Results     string    `json:"results"`
+}
+
+// historyResult is a result in the results file of a run, with the outcome
+// and time of its checks that the JSONL output leaves out.
+type historyResult struct {
+	*Result
+	Failed     bool    `json:"failed,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+}
+
+// checked reports whether the check of r ran, rather than being replayed,
+// trusted or impossible to run.
+func (r historyResult) checked() bool {
+	return !r.Cached && r.TrustedFrom == "" && r.ExitCode != -1
+}
+
+// historyRecorder adds a run to the history: its results as they are
+// written, and its entry in the log of runs once it is done.
+type historyRecorder struct {
+	dir     string
+	run     HistoryRun
+	results *resultsFile
+	encoder *json.Encoder
+}
+
+// startHistory starts recording a run with the given arguments in the
+// history of hashesFile.
+func startHistory(hashesFile string, args []string) (*historyRecorder, error) {
+	dir := historyDir(hashesFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	run := HistoryRun{
+		ID:     fmt.Sprintf("%s-%06x", now.Format("20060102T150405Z"), rand.Uint32()>>8),
+		Time:   now,
+		Args:   args,
+		Commit: gitCommit(),
+	}
+	run.Results = run.ID + ".jsonl.gz"
+	results, err := openResultsFile(filepath.Join(dir, run.Results), false)
+	if err != nil {
+		return nil, err
+	}
+	return &historyRecorder{dir: dir, run: run, results: results, encoder: json.NewEncoder(results)}, nil
+}
+
+func (h *historyRecorder) add(result *Result) error {
+	return h.encoder.Encode(historyResult{result, result.failed, milliseconds(result.duration)})
+}
+
+// finish closes the results of the run and adds it to the log of runs.
+func (h *historyRecorder) finish(stats *RunStats, interrupted bool) error {
+	if err := h.results.Close(); err != nil {
+		return err
+	}
+	h.run.Stats = stats
+	h.run.Interrupted = interrupted
+	data, err := json.Marshal(h.run)
+	if err != nil {
+		return err
+	}
+	return appendFile(filepath.Join(h.dir, historyRunsFile), string(data)+"\n")
+}
+
+// gitCommit returns the commit checked out in the working directory, or ""
+// outside a git repository.
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// loadHistory returns the last n runs in the history directory dir, oldest
+// first.
+func loadHistory(dir string, n int) ([]HistoryRun, error) {
+	f, err := os.Open(filepath.Join(dir, historyRunsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []HistoryRun
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var run HistoryRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("invalid run entry: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return runs[max(len(runs)-n, 0):], nil
+}
+
+// loadRunResults returns the results of run.
+func loadRunResults(dir string, run HistoryRun) ([]historyResult, error) {
+	f, err := os.Open(filepath.Join(dir, run.Results))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []historyResult
+	decoder := json.NewDecoder(gz)
+	for {
+		var result historyResult
+		if err := decoder.Decode(&result); err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+}
+
+// FailingFile is a file that failed in the runs queried.
+type FailingFile struct {
+	Filename   string `json:"filename"`
+	Failures   int    `json:"failures"`
+	Runs       int    `json:"runs"`
+	LastFailed string `json:"last_failed"`
+}
+
+// SlowerFile is a file whose check took longer in its latest run than
+// before.
+type SlowerFile struct {
+	Filename   string  `json:"filename"`
+	DurationMS float64 `json:"duration_ms"`
+	BaselineMS float64 `json:"baseline_ms"`
+	Ratio      float64 `json:"ratio"`
+}
+
+// FlakyFile is a file whose check outcome changed while its content didn't.
+type FlakyFile struct {
+	Filename string `json:"filename"`
+	Flips    int    `json:"flips"`
+	Checks   int    `json:"checks"`
+	Failures int    `json:"failures"`
+}
+
+// runHistory implements the history command: action is one of runs,
+// failing, slower or flaky, queried over the last cfg.historyRuns runs. It
+// returns the process exit code.
+func runHistory(cfg Config, output io.Writer) int {
+	dir := historyDir(cfg.hashesFile)
+	runs, err := loadHistory(dir, cfg.historyRuns)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: no run history for '%s' (runs record it with --history)\n", cfg.hashesFile)
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading run history: %v\n", err)
+		return exitError
+	}
+
+	encoder := json.NewEncoder(output)
+	encode := func(list []any) int {
+		for _, record := range list {
+			if err := encoder.Encode(record); err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding record: %v\n", err)
+				return exitError
+			}
+		}
+		return 0
+	}
+
+	if cfg.historyAction == "runs" {
+		return encode(records(runs))
+	}
+
+	var query func(runs []HistoryRun, results [][]historyResult) []any
+	switch cfg.historyAction {
+	case "failing":
+		query = failingFiles
+	case "slower":
+		query = slowerFiles
+	case "flaky":
+		query = flakyFiles
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown history action '%s' (expected runs, failing, slower or flaky)\n", cfg.historyAction)
+		return exitUsage
+	}
+
+	// Runs whose results are gone are left out of the queries
+	var loaded []HistoryRun
+	var results [][]historyResult
+	for _, run := range runs {
+		r, err := loadRunResults(dir, run)
+		if err != nil {
+			if !cfg.quiet {
+				logError("Error reading results of run %s: %v\n", run.ID, err)
+			}
+			continue
+		}
+		loaded = append(loaded, run)
+		results = append(results, r)
+	}
+	return encode(query(loaded, results))
+}
+
+// failingFiles returns the files that failed in runs, most failures first.
+func failingFiles(runs []HistoryRun, results [][]historyResult) []any {
+	files := make(map[string]*FailingFile)
+	for i, run := range runs {
+		for _, result := range results[i] {
+			file := files[result.Filename]
+			if file == nil {
+				file = &FailingFile{Filename: result.Filename}
+				files[result.Filename] = file
+			}
+			file.Runs++
+			if result.Failed {
+				file.Failures++
+				file.LastFailed = run.ID
+			}
+		}
+	}
+
+	var failing []*FailingFile
+	for _, file := range files {
+		if file.Failures > 0 {
+			failing = append(failing, file)
+		}
+	}
+	sort.Slice(failing, func(i, j int) bool {
+		if failing[i].Failures != failing[j].Failures {
+			return failing[i].Failures > failing[j].Failures
+		}
+		return failing[i].Filename < failing[j].Filename
+	})
+	return records(failing)
+}
+
+// slowerFiles returns the files whose check time in the latest run that
+// checked them regressed from their median before, most regressed first.
+func slowerFiles(runs []HistoryRun, results [][]historyResult) []any {
+	durations := make(map[string][]float64)
+	for i := range runs {
+		for _, result := range results[i] {
+			if result.checked() {
+				durations[result.Filename] = append(durations[result.Filename], result.DurationMS)
+			}
+		}
+	}
+
+	var slower []SlowerFile
+	for filename, times := range durations {
+		if len(times) < 2 {
+			continue
+		}
+		latest := times[len(times)-1]
+		baseline := median(times[:len(times)-1])
+		if latest >= baseline*slowerFactor && latest-baseline >= slowerMinMS {
+			slower = append(slower, SlowerFile{filename, latest, baseline, latest / max(baseline, 0.001)})
+		}
+	}
+	sort.Slice(slower, func(i, j int) bool {
+		if slower[i].Ratio != slower[j].Ratio {
+			return slower[i].Ratio > slower[j].Ratio
+		}
+		return slower[i].Filename < slower[j].Filename
+	})
+	return records(slower)
+}
+
+// flakyFiles returns the files whose outcome changed between checks of the
+// same content, most changes first.
+func flakyFiles(runs []HistoryRun, results [][]historyResult) []any {
+	files := make(map[string]*FlakyFile)
+	last := make(map[string]bool) // the last outcome of each file and hash
+	for i := range runs {
+		for _, result := range results[i] {
+			if !result.checked() {
+				continue
+			}
+			file := files[result.Filename]
+			if file == nil {
+				file = &FlakyFile{Filename: result.Filename}
+				files[result.Filename] = file
+			}
+			file.Checks++
+			if result.Failed {
+				file.Failures++
+			}
+			key := result.Filename + "\x00" + result.Hash
+			if failed, ok := last[key]; ok && failed != result.Failed {
+				file.Flips++
+			}
+			last[key] = result.Failed
+		}
+	}
+
+	var flaky []*FlakyFile
+	for _, file := range files {
+		if file.Flips > 0 {
+			flaky = append(flaky, file)
+		}
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Flips != flaky[j].Flips {
+			return flaky[i].Flips > flaky[j].Flips
+		}
+		return flaky[i].Filename < flaky[j].Filename
+	})
+	return records(flaky)
+}
+
+// records returns items as records to encode.
+func records[T any](items []T) []any {
+	records := make([]any, len(items))
+	for i, item := range items {
+		records[i] = item
+	}
+	return records
+}
+
+// median returns the median of values.
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryRecorder(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	pass := filepath.Join(tempDir, "pass.txt")
+	fail := filepath.Join(tempDir, "fail.txt")
+	for _, file := range []string{pass, fail} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range 3 {
+		recorder, err := startHistory(hashesFile, []string{"-c", "mycheck"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{
+			command:    "case $FILE in *fail*) exit 1;; esac",
+			hashesFile: hashesFile,
+			workers:    2,
+			quiet:      true,
+			historyRun: recorder,
+		}
+		summary := processFiles([]string{pass, fail}, cfg, loadAuditFile(hashesFile), io.Discard)
+		if err := recorder.finish(summary.stats(), i == 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := loadHistory(historyDir(hashesFile), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Interrupted || !runs[1].Interrupted {
+		t.Fatalf("expected the last 2 of 3 runs, oldest first, got %+v", runs)
+	}
+	if runs[1].Stats == nil || runs[1].Stats.Failed != 1 || runs[1].Args[1] != "mycheck" {
+		t.Errorf("expected the stats and arguments of the run, got %+v", runs[1])
+	}
+
+	results, err := loadRunResults(historyDir(hashesFile), runs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Failed != (result.Filename == fail) || result.Hash == "" {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+
+	// Every run failed the same file
+	var buf bytes.Buffer
+	if code := runHistory(Config{hashesFile: hashesFile, historyAction: "failing", historyRuns: 10}, &buf); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	var failing FailingFile
+	if err := json.Unmarshal(buf.Bytes(), &failing); err != nil || failing.Filename != fail || failing.Failures != 3 || failing.Runs != 3 {
+		t.Errorf("expected %s to fail in 3 runs, got %s", fail, buf.String())
+	}
+
+	if code := runHistory(Config{hashesFile: hashesFile, historyAction: "bogus", historyRuns: 10}, io.Discard); code != exitUsage {
+		t.Errorf("expected exit code %d for an unknown action, got %d", exitUsage, code)
+	}
+	if code := runHistory(Config{hashesFile: filepath.Join(tempDir, "other.jsonl"), historyAction: "runs", historyRuns: 10}, io.Discard); code != exitError {
+		t.Errorf("expected exit code %d without history, got %d", exitError, code)
+	}
+}
+
+// historyOf returns runs with the given results, one slice per run.
+func historyOf(results ...[]historyResult) ([]HistoryRun, [][]historyResult) {
+	runs := make([]HistoryRun, len(results))
+	for i := range runs {
+		runs[i].ID = string(rune('a' + i))
+	}
+	return runs, results
+}
+
+func TestHistoryQueries(t *testing.T) {
+	checked := func(filename, hash string, failed bool, ms float64) historyResult {
+		return historyResult{Result: &Result{Filename: filename, Hash: hash}, Failed: failed, DurationMS: ms}
+	}
+	cached := func(filename string, failed bool) historyResult {
+		return historyResult{Result: &Result{Filename: filename, Cached: true}, Failed: failed}
+	}
+	runs, results := historyOf(
+		[]historyResult{checked("flaky", "h1", false, 5), checked("slow", "h", false, 100), checked("fixed", "f1", true, 1)},
+		[]historyResult{checked("flaky", "h1", true, 5), checked("slow", "h", false, 120), checked("fixed", "f2", false, 1)},
+		[]historyResult{checked("flaky", "h1", false, 5), checked("slow", "h", false, 300), cached("fixed", false)},
+		[]historyResult{checked("flaky", "h2", true, 5), cached("slow", false), cached("fixed", false)},
+	)
+
+	failing := failingFiles(runs, results)
+	if len(failing) != 2 || failing[0].(*FailingFile).Filename != "flaky" || failing[0].(*FailingFile).Failures != 2 || failing[0].(*FailingFile).LastFailed != "d" {
+		t.Errorf("expected flaky to fail most, last in run d, got %+v", failing)
+	}
+
+	// A changed hash is a new file as far as flakiness goes
+	flaky := flakyFiles(runs, results)
+	if len(flaky) != 1 || *flaky[0].(*FlakyFile) != (FlakyFile{Filename: "flaky", Flips: 2, Checks: 4, Failures: 2}) {
+		t.Errorf("expected flaky to flip twice, got %+v", flaky)
+	}
+
+	// Replayed results don't count as check times
+	slower := slowerFiles(runs, results)
+	if len(slower) != 1 || slower[0].(SlowerFile) != (SlowerFile{Filename: "slow", DurationMS: 300, BaselineMS: 110, Ratio: 300.0 / 110}) {
+		t.Errorf("expected slow to regress from 110ms to 300ms, got %+v", slower)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{[]float64{3}, 3},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 2, 3}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
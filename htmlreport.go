@@ -0,0 +1,156 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+//go:embed htmlreport.html
+var htmlReportTemplate string
+
+// htmlRow is a file in the table of an HTML report.
+type htmlRow struct {
+	Filename   string
+	Outcome    string
+	Status     string
+	ExitCode   int
+	DurationMS float64
+	Rule       string
+	Output     string
+}
+
+// htmlBar is a segment of a bar chart of an HTML report, with its offset
+// and width in percent.
+type htmlBar struct {
+	Label  string
+	Class  string
+	Count  int
+	Offset float64
+	Width  float64
+}
+
+// htmlSlowFile is a file in the chart of the slowest files of an HTML
+// report, with the width of its bar in percent of the slowest.
+type htmlSlowFile struct {
+	Filename   string
+	DurationMS float64
+	Width      float64
+}
+
+// htmlFuncs are the functions of the HTML report template.
+var htmlFuncs = template.FuncMap{
+	"seconds": formatMS,
+	"percent": func(ratio float64) string { return fmt.Sprintf("%.0f%%", ratio*100) },
+}
+
+// htmlReport collects the results of a run into a self-contained HTML page
+// with summary charts and a sortable, filterable table of files.
+type htmlReport struct {
+	cfg  Config
+	rows []htmlRow
+}
+
+func newHTMLReport(cfg Config) *htmlReport {
+	return &htmlReport{cfg: cfg}
+}
+
+func (r *htmlReport) add(result *Result) {
+	label, _ := outcome(result)
+	r.rows = append(r.rows, htmlRow{
+		Filename:   result.Filename,
+		Outcome:    label,
+		Status:     classify(result),
+		ExitCode:   result.ExitCode,
+		DurationMS: milliseconds(result.duration),
+		Rule:       result.Rule,
+		Output:     result.output,
+	})
+}
+
+// write renders the page to the --html-report file, with the statistics of
+// the run, which is done by the time reports are written.
+func (r *htmlReport) write() error {
+	tmpl, err := template.New("report").Funcs(htmlFuncs).Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(r.rows, func(i, j int) bool {
+		return r.rows[i].Filename < r.rows[j].Filename
+	})
+	stats := r.cfg.summary.stats()
+	counts := make(map[string]int)
+	for _, row := range r.rows {
+		counts[row.Outcome]++
+	}
+
+	data := struct {
+		Generated string
+		Command   string
+		Stats     *RunStats
+		Outcomes  []htmlBar
+		Statuses  []htmlBar
+		Slowest   []htmlSlowFile
+		Rows      []htmlRow
+	}{
+		Generated: time.Now().Format(time.RFC1123),
+		Command:   r.cfg.command,
+		Stats:     stats,
+		Outcomes: htmlBars([]htmlBar{
+			{Label: "Passed", Class: "pass", Count: counts[outcomePass]},
+			{Label: "Failed", Class: "fail", Count: counts[outcomeFail]},
+			{Label: "Skipped", Class: "skip", Count: counts[outcomeSkip]},
+			{Label: "Errors", Class: "error", Count: counts[outcomeError]},
+		}),
+		Statuses: htmlBars([]htmlBar{
+			{Label: "New", Class: "new", Count: stats.New},
+			{Label: "Changed", Class: "changed", Count: stats.Changed},
+			{Label: "Unchanged", Class: "unchanged", Count: stats.Unchanged},
+			{Label: "Renamed", Class: "renamed", Count: stats.Renamed},
+			{Label: "Trusted", Class: "trusted", Count: stats.Trusted},
+		}),
+		Rows: r.rows,
+	}
+	for _, t := range stats.Slowest {
+		width := 0.0
+		if stats.CommandTime.MaxMS > 0 {
+			width = t.DurationMS / stats.CommandTime.MaxMS * 100
+		}
+		data.Slowest = append(data.Slowest, htmlSlowFile{t.Filename, t.DurationMS, width})
+	}
+
+	f, err := os.Create(r.cfg.htmlReportFile)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// htmlBars lays out bars as the segments of a stacked bar, leaving out
+// empty ones.
+func htmlBars(bars []htmlBar) []htmlBar {
+	total := 0
+	for _, bar := range bars {
+		total += bar.Count
+	}
+	var segments []htmlBar
+	offset := 0.0
+	for _, bar := range bars {
+		if bar.Count == 0 {
+			continue
+		}
+		bar.Offset = offset
+		bar.Width = float64(bar.Count) / float64(total) * 100
+		offset += bar.Width
+		segments = append(segments, bar)
+	}
+	return segments
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTMLReport(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	reportFile := filepath.Join(tempDir, "report.html")
+	pass := filepath.Join(tempDir, "pass.txt")
+	fail := filepath.Join(tempDir, "fail.txt")
+	for _, file := range []string{pass, fail} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{
+		command:        "case $FILE in *fail*) echo '<script>alert(1)</script>'; exit 2;; esac",
+		audit:          true,
+		update:         true,
+		hashesFile:     hashesFile,
+		htmlReportFile: reportFile,
+		workers:        2,
+		quiet:          true,
+	}
+	processFiles([]string{pass, fail}, cfg, loadAuditFile(hashesFile), io.Discard)
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		`<tr data-outcome="FAIL">`,
+		`<tr data-outcome="PASS">`,
+		`<title>Failed: 1</title>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected report to contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>alert") {
+		t.Error("expected check output to be escaped")
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") || strings.Contains(page, " src=") {
+		t.Error("expected a self-contained report with no network fetches")
+	}
+	if strings.Index(page, fail) > strings.Index(page, `<tr data-outcome="PASS">`) {
+		t.Error("expected files sorted by name")
+	}
+}
+
+func TestHTMLBars(t *testing.T) {
+	bars := htmlBars([]htmlBar{
+		{Label: "Passed", Count: 3},
+		{Label: "Failed", Count: 0},
+		{Label: "Errors", Count: 1},
+	})
+	if len(bars) != 2 {
+		t.Fatalf("expected empty bars left out, got %+v", bars)
+	}
+	if bars[0].Offset != 0 || bars[0].Width != 75 || bars[1].Offset != 75 || bars[1].Width != 25 {
+		t.Errorf("unexpected layout: %+v", bars)
+	}
+	if htmlBars([]htmlBar{{Label: "Passed"}}) != nil {
+		t.Error("expected no bars when nothing was counted")
+	}
+}
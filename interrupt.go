@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptExitCode is the exit status of a run stopped by SIGINT or
+// SIGTERM, as a shell reports a process killed by SIGINT.
+const interruptExitCode = 130
+
+// interrupter handles SIGINT and SIGTERM during a run. The first signal stops
+// the workers from starting new files while running checks finish; a second
+// one kills the running checks as well. Either way the run ends normally, so
+// the results finished so far are written and merged into the hashes file.
+type interrupter struct {
+	signals  chan os.Signal
+	stopping chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// newInterrupter starts handling signals until stop is called.
+func newInterrupter(quiet bool) *interrupter {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := &interrupter{
+		signals:  make(chan os.Signal, 2),
+		stopping: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	signal.Notify(in.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range in.signals {
+			if !in.interrupted() {
+				if !quiet {
+					logError("Interrupted: finishing running checks and saving results (interrupt again to stop them)\n")
+				}
+				close(in.stopping)
+				continue
+			}
+			if !quiet {
+				logError("Interrupted again: stopping running checks\n")
+			}
+			in.cancel()
+		}
+	}()
+	return in
+}
+
+// interrupted reports whether a signal was received.
+func (in *interrupter) interrupted() bool {
+	if in == nil {
+		return false
+	}
+	select {
+	case <-in.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// done returns a channel closed when a signal is received.
+func (in *interrupter) done() <-chan struct{} {
+	if in == nil {
+		return nil
+	}
+	return in.stopping
+}
+
+// context returns the context running checks are killed with.
+func (in *interrupter) context() context.Context {
+	if in == nil {
+		return context.Background()
+	}
+	return in.ctx
+}
+
+// stop restores the default signal handling.
+func (in *interrupter) stop() {
+	signal.Stop(in.signals)
+	close(in.signals)
+	in.cancel()
+}
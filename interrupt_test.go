@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInterrupter(t *testing.T) {
+	var none *interrupter
+	if none.interrupted() || none.context().Err() != nil {
+		t.Error("expected a nil interrupter never to interrupt")
+	}
+
+	in := newInterrupter(true)
+	defer in.stop()
+	if in.interrupted() {
+		t.Fatal("expected no interrupt before a signal")
+	}
+
+	in.signals <- os.Interrupt
+	waitFor(t, in.interrupted)
+	if in.context().Err() != nil {
+		t.Error("expected running checks to continue after the first signal")
+	}
+
+	in.signals <- os.Interrupt
+	waitFor(t, func() bool { return in.context().Err() != nil })
+}
+
+func TestProcessFilesInterrupted(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		file := filepath.Join(tempDir, name)
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	cfg := Config{command: "true", update: true, hashesFile: hashesFile, workers: 1, quiet: true}
+	cfg.interrupt = newInterrupter(true)
+	defer cfg.interrupt.stop()
+	cfg.interrupt.signals <- os.Interrupt
+	waitFor(t, cfg.interrupt.interrupted)
+
+	summary := processFiles(files, cfg, nil, io.Discard)
+	if summary.Processed != 0 {
+		t.Errorf("expected no files to start after an interrupt, got %d", summary.Processed)
+	}
+}
+
+func TestRunCommandKilledOnSecondInterrupt(t *testing.T) {
+	cfg := Config{command: "sleep 10; true", quiet: true}
+	cfg.interrupt = newInterrupter(true)
+	defer cfg.interrupt.stop()
+
+	done := make(chan int)
+	go func() {
+		done <- runCommand(cfg, "file.txt", nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cfg.interrupt.signals <- os.Interrupt
+	cfg.interrupt.signals <- os.Interrupt
+
+	select {
+	case exitCode := <-done:
+		if exitCode != -1 {
+			t.Errorf("expected exit code -1 for a killed check, got %d", exitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the running check to be killed")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// journalRecord is one record of a journal: a JSONL hashes file written in
+// journal mode, or the delta log of a binary hashes file. It is an entry or,
+// with Deleted set, the removal of one. Later records win.
+type journalRecord struct {
+	AuditEntry
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// readJournal reads the records from r, keeping the last one for each file.
+func readJournal(r io.Reader) (map[string]journalRecord, error) {
+	records := make(map[string]journalRecord)
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record journalRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			// A record cut off by a crash ends the journal
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, nil
+			}
+			return nil, err
+		}
+		records[record.Filename] = record
+	}
+}
+
+// appendJournal appends records to the journal at path, holding the lock of
+// hashesFile so concurrent runs and compaction don't interleave with it.
+func appendJournal(path, hashesFile string, records []journalRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := lockHashesFile(hashesFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := endLastRecord(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// endLastRecord makes the journal f end with a newline before records are
+// appended. A last line without one is ended if it holds a whole record, and
+// otherwise is what a crash left of a record and is dropped, so the next
+// record isn't glued to it.
+func endLastRecord(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Find the start of the last line
+	size := info.Size()
+	start := size
+	buf := make([]byte, 4096)
+	for start > 0 {
+		n := min(start, int64(len(buf)))
+		if _, err := f.ReadAt(buf[:n], start-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			start -= n - int64(i) - 1
+			break
+		}
+		start -= n
+	}
+	if start == size {
+		return nil
+	}
+
+	last := make([]byte, size-start)
+	if _, err := f.ReadAt(last, start); err != nil {
+		return err
+	}
+	if json.Valid(last) {
+		_, err := f.Write([]byte("\n"))
+		return err
+	}
+	return f.Truncate(start)
+}
+
+// lockHashesFile takes an exclusive lock on hashesFile, held in a .lock file
+// next to it so it survives the hashes file being replaced, and returns the
+// function releasing it.
+func lockHashesFile(hashesFile string) (func(), error) {
+	f, err := os.OpenFile(hashesFile+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// journal appends the results of an update run to the hashes file as they
+// arrive, instead of collecting them in a .new file to merge at the end.
+type journal struct {
+	hashesFile string
+	path       string
+	auditMap   map[string]AuditEntry
+}
+
+// openJournal prepares hashesFile for journal mode. Binary hashes files
+// journal to their delta log; manifests can't be journaled to, see
+// checkWritable.
+func openJournal(hashesFile string, auditMap map[string]AuditEntry) (*journal, error) {
+	if err := checkWritable(hashesFile); err != nil {
+		return nil, err
+	}
+	j := &journal{hashesFile: hashesFile, path: hashesFile, auditMap: auditMap}
+	if detectFormat(hashesFile) == formatBinary {
+		j.path = deltaLogPath(hashesFile)
+	}
+	return j, nil
+}
+
+// add records entry. Entries the hashes file already holds, such as the
+// replayed results of unchanged files, are skipped.
+func (j *journal) add(entry AuditEntry) error {
+	var records []journalRecord
+	if entry.RenamedFrom != "" {
+		// The entry of a renamed file moves to its new path
+		records = append(records, journalRecord{AuditEntry: AuditEntry{Filename: entry.RenamedFrom}, Deleted: true})
+		entry.RenamedFrom = ""
+	} else if existing, ok := j.auditMap[entry.Filename]; ok && existing.equal(entry) {
+		return nil
+	}
+	records = append(records, journalRecord{AuditEntry: entry})
+	return appendJournal(j.path, j.hashesFile, records)
+}
+
+// compactHashesFile rewrites hashesFile sorted and with one entry per file,
+// dropping superseded journal records, and returns the number of entries.
+func compactHashesFile(hashesFile string) (int, error) {
+	unlock, err := lockHashesFile(hashesFile)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	auditMap := loadAuditFile(hashesFile)
+	return len(auditMap), writeAuditFile(hashesFile, auditMap)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadJournal(t *testing.T) {
+	input := `{"filename":"a.txt","hash":"old"}
+{"filename":"b.txt","hash":"b"}
+{"filename":"a.txt","hash":"new"}
+{"filename":"b.txt","deleted":true}
+{"filename":"c.txt","ha`
+
+	records, err := readJournal(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records["a.txt"].Hash != "new" {
+		t.Errorf("expected the last record to win, got %+v", records["a.txt"])
+	}
+	if !records["b.txt"].Deleted {
+		t.Error("expected b.txt to be removed")
+	}
+	if _, ok := records["c.txt"]; ok {
+		t.Error("expected the cut-off record to be ignored")
+	}
+
+	if _, err := readJournal(strings.NewReader("{\"filename\": }\n")); err == nil {
+		t.Error("expected an error for a malformed record")
+	}
+}
+
+func TestJournalAdd(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+	auditMap := map[string]AuditEntry{
+		"same.txt": {Filename: "same.txt", Hash: testHash1},
+		"old.txt":  {Filename: "old.txt", Hash: testHash2},
+	}
+	if err := writeAuditFile(hashesFile, auditMap); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := openJournal(hashesFile, auditMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []AuditEntry{
+		{Filename: "same.txt", Hash: testHash1},
+		{Filename: "new.txt", Hash: testHash2, RenamedFrom: "old.txt"},
+		{Filename: "same.txt", Hash: testHash2, ExitCode: 1},
+	}
+	for _, entry := range entries {
+		if err := j.add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(hashesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 5 {
+		t.Errorf("expected 2 entries and 3 appended records, got %d lines:\n%s", lines, data)
+	}
+
+	loaded := loadAuditFile(hashesFile)
+	if _, ok := loaded["old.txt"]; ok || loaded["new.txt"].Hash != testHash2 {
+		t.Errorf("expected the renamed entry to move, got %+v", loaded)
+	}
+	if loaded["same.txt"].ExitCode != 1 || loaded["new.txt"].RenamedFrom != "" {
+		t.Errorf("expected the last records to win, got %+v", loaded)
+	}
+
+	count, err := compactHashesFile(hashesFile)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 entries after compaction, got %d, %v", count, err)
+	}
+	if data, _ := os.ReadFile(hashesFile); strings.Count(string(data), "\n") != 2 || strings.Contains(string(data), "deleted") {
+		t.Errorf("expected compacted file, got:\n%s", data)
+	}
+}
+
+func TestJournalKeepsManifest(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := os.WriteFile(hashesFile, []byte(testHash1+"  a.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	auditMap := loadAuditFile(hashesFile)
+	if _, err := openJournal(hashesFile, auditMap); err == nil {
+		t.Error("expected an error journaling to a manifest")
+	}
+	if format := detectFormat(hashesFile); format != formatCoreutils {
+		t.Errorf("expected manifest to be left alone, got %q", format)
+	}
+}
+
+func TestAppendJournalConcurrent(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("file%d.txt", i)
+			record := journalRecord{AuditEntry: AuditEntry{Filename: name, Hash: strings.Repeat("a", 4096)}}
+			if err := appendJournal(hashesFile, hashesFile, []journalRecord{record}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if loaded := loadAuditFile(hashesFile); len(loaded) != 20 {
+		t.Errorf("expected 20 intact entries, got %d", len(loaded))
+	}
+}
+
+func TestAppendJournalAfterTornRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"torn record", "{\"filename\":\"a.txt\",\"hash\":\"a\"}\n{\"filename\":\"b.txt\",\"ha", []string{"a.txt", "c.txt"}},
+		{"record without newline", "{\"filename\":\"a.txt\",\"hash\":\"a\"}\n{\"filename\":\"b.txt\",\"hash\":\"b\"}", []string{"a.txt", "b.txt", "c.txt"}},
+		{"only a torn record", "{\"filename\":\"b.txt\",\"ha", []string{"a.txt", "c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+			if err := os.WriteFile(hashesFile, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			record := journalRecord{AuditEntry: AuditEntry{Filename: "c.txt", Hash: "c"}}
+			if err := appendJournal(hashesFile, hashesFile, []journalRecord{record}); err != nil {
+				t.Fatal(err)
+			}
+			record.Filename = "a.txt"
+			if err := appendJournal(hashesFile, hashesFile, []journalRecord{record}); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(hashesFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			records, err := readJournal(f)
+			if err != nil {
+				t.Fatalf("expected the journal to load after appending, got %v", err)
+			}
+			for _, name := range tt.want {
+				if _, ok := records[name]; !ok {
+					t.Errorf("expected a record for %s, got %+v", name, records)
+				}
+			}
+			if len(records) != len(tt.want) {
+				t.Errorf("expected %d records, got %+v", len(tt.want), records)
+			}
+		})
+	}
+}
+
+func TestProcessFilesJournal(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	file := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{command: "true", audit: true, update: true, journal: true, hashesFile: hashesFile, workers: 1, quiet: true}
+	processFiles([]string{file}, cfg, loadAuditFile(hashesFile), io.Discard)
+
+	if _, err := os.Stat(hashesFile + ".new"); !os.IsNotExist(err) {
+		t.Error("expected no .new file in journal mode")
+	}
+	if _, ok := loadAuditFile(hashesFile)[file]; !ok {
+		t.Fatal("expected the result to be in the hashes file before merging")
+	}
+
+	// An unchanged file appends nothing
+	before, _ := os.ReadFile(hashesFile)
+	processFiles([]string{file}, cfg, loadAuditFile(hashesFile), io.Discard)
+	if after, _ := os.ReadFile(hashesFile); string(after) != string(before) {
+		t.Errorf("expected hashes file to be left alone, got:\n%s", after)
+	}
+}
+
+func TestMergeHashFilesWaitsForLock(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+	if err := writeAuditFile(hashesFile, map[string]AuditEntry{"a.txt": {Filename: "a.txt", Hash: testHash1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuditFile(hashesFile+".new", map[string]AuditEntry{"b.txt": {Filename: "b.txt", Hash: testHash2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A journal append holding the lock finishes before the merge reads
+	unlock, err := lockHashesFile(hashesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		mergeHashFiles(hashesFile)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("expected the merge to wait for the lock")
+	default:
+	}
+	f, err := os.OpenFile(hashesFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"filename":"c.txt","hash":"` + testHash1 + "\"}\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	unlock()
+	<-done
+
+	loaded := loadAuditFile(hashesFile)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, ok := loaded[name]; !ok {
+			t.Errorf("expected an entry for %s, got %+v", name, loaded)
+		}
+	}
+}
+
+func TestJournalAddSkipsUnchangedEntry(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+	checkedAt := time.Now().Truncate(time.Second)
+	stored := AuditEntry{Filename: "a.txt", Hash: testHash1, CheckedAt: checkedAt}
+	if err := writeAuditFile(hashesFile, map[string]AuditEntry{"a.txt": stored}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(hashesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry read back has the same instant in another representation
+	auditMap := loadAuditFile(hashesFile)
+	j, err := openJournal(hashesFile, auditMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.add(stored); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(hashesFile); string(after) != string(before) {
+		t.Errorf("expected the unchanged entry not to be appended, got:\n%s", after)
+	}
+}
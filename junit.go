@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// JUnit XML elements, as understood by Jenkins and GitLab.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+
+	duration time.Duration
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitReport collects the results of a run into a JUnit XML report with
+// one test suite per check and one test case per file.
+type junitReport struct {
+	cfg    Config
+	suites map[string]*junitTestSuite
+}
+
+func newJUnitReport(cfg Config) *junitReport {
+	return &junitReport{cfg: cfg, suites: make(map[string]*junitTestSuite)}
+}
+
+// add records result as a test case. Files skipped because they are
+// unchanged or trusted are skipped test cases; a replayed failure is still
+// a failure.
+func (r *junitReport) add(result *Result) {
+	name := checkName(r.cfg, result)
+	suite, ok := r.suites[name]
+	if !ok {
+		suite = &junitTestSuite{Name: name}
+		r.suites[name] = suite
+	}
+
+	testCase := junitTestCase{
+		Name:      result.Filename,
+		Classname: name,
+		Time:      junitTime(result.duration),
+	}
+	switch {
+	case result.ExitCode == -1:
+		testCase.Error = &junitFailure{Message: failureMessage(result), Type: "error", Output: result.output}
+		suite.Errors++
+	case result.failed:
+		testCase.Failure = &junitFailure{Message: failureMessage(result), Type: "exit code", Output: result.output}
+		suite.Failures++
+	case result.Cached:
+		testCase.Skipped = &junitSkipped{Message: "unchanged"}
+		suite.Skipped++
+	case result.TrustedFrom != "":
+		testCase.Skipped = &junitSkipped{Message: "same content as " + result.TrustedFrom}
+		suite.Skipped++
+	}
+	suite.Tests++
+	suite.duration += result.duration
+	suite.Cases = append(suite.Cases, testCase)
+}
+
+// write writes the report to the --junit file, with suites and test cases
+// sorted by name so reports of the same files compare cleanly.
+func (r *junitReport) write() error {
+	report := junitTestSuites{Name: "ghc"}
+	var duration time.Duration
+	for _, suite := range r.suites {
+		sort.Slice(suite.Cases, func(i, j int) bool {
+			return suite.Cases[i].Name < suite.Cases[j].Name
+		})
+		suite.Time = junitTime(suite.duration)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		duration += suite.duration
+		report.Suites = append(report.Suites, *suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool {
+		return report.Suites[i].Name < report.Suites[j].Name
+	})
+	report.Time = junitTime(duration)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(r.cfg.junitFile, append(data, '\n'), 0644)
+}
+
+// junitTime formats d in seconds, as JUnit time attributes are.
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
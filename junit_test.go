@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJUnitReport(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	reportFile := filepath.Join(tempDir, "report.xml")
+	pass := filepath.Join(tempDir, "pass.txt")
+	fail := filepath.Join(tempDir, "fail.txt")
+	for _, file := range []string{pass, fail} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{
+		command:    "case $FILE in *fail*) echo 'bad <input>'; exit 2;; esac",
+		audit:      true,
+		update:     true,
+		hashesFile: hashesFile,
+		junitFile:  reportFile,
+		workers:    2,
+		quiet:      true,
+	}
+	readReport := func() junitTestSuites {
+		t.Helper()
+		data, err := os.ReadFile(reportFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report junitTestSuites
+		if err := xml.Unmarshal(data, &report); err != nil {
+			t.Fatalf("invalid report: %v\n%s", err, data)
+		}
+		if len(report.Suites) != 1 || report.Suites[0].Name != cfg.command || len(report.Suites[0].Cases) != 2 {
+			t.Fatalf("expected one suite named after the check with 2 cases, got %+v", report)
+		}
+		return report
+	}
+
+	processFiles([]string{pass, fail}, cfg, loadAuditFile(hashesFile), io.Discard)
+	mergeHashFiles(hashesFile)
+
+	report := readReport()
+	if report.Tests != 2 || report.Failures != 1 || report.Skipped != 0 {
+		t.Errorf("expected 2 tests with 1 failure, got %+v", report)
+	}
+	failure := report.Suites[0].Cases[0].Failure
+	if report.Suites[0].Cases[0].Name != fail || failure == nil {
+		t.Fatalf("expected failure for %s first, got %+v", fail, report.Suites[0].Cases)
+	}
+	if failure.Message != "exit code 2" || !strings.Contains(failure.Output, "bad <input>") {
+		t.Errorf("expected exit code and output in the failure, got %+v", failure)
+	}
+
+	// Unchanged files are skipped; a cached failure still fails
+	processFiles([]string{pass, fail}, cfg, loadAuditFile(hashesFile), io.Discard)
+
+	report = readReport()
+	if report.Tests != 2 || report.Failures != 1 || report.Skipped != 1 {
+		t.Errorf("expected 1 failure and 1 skipped test, got %+v", report)
+	}
+	cases := report.Suites[0].Cases
+	if cases[0].Failure == nil || cases[0].Failure.Message != "exit code 2 (cached)" {
+		t.Errorf("expected cached failure, got %+v", cases[0].Failure)
+	}
+	if cases[1].Skipped == nil || cases[1].Skipped.Message != "unchanged" {
+		t.Errorf("expected unchanged file to be skipped, got %+v", cases[1])
+	}
+}
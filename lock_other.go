@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile does nothing on platforms without flock; runs sharing a hashes
+// file there must not overlap.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -1,65 +1,259 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"text/template"
+	"time"
 )
 
 type Config struct {
-	command       string
-	hashesFile    string
-	successCodes  map[int]bool
-	errorCodes    map[int]bool
-	workers       int
-	filterOnCodes bool
-	audit         bool
-	update        bool
-	showProgress  bool
-	quiet         bool
+	command           string
+	hashesFile        string
+	successCodes      map[int]bool
+	errorCodes        map[int]bool
+	workers           int
+	filterOnCodes     bool
+	audit             bool
+	update            bool
+	showProgress      bool
+	quiet             bool
+	timeout           time.Duration
+	include           []string
+	exclude           []string
+	rules             []Rule
+	ruleMode          string
+	unmatched         string
+	subcommand        string
+	dbAction          string
+	historyAction     string
+	historyRuns       int
+	dryRun            bool
+	format            string
+	ignoreMissing     bool
+	strict            bool
+	prune             bool
+	pruneOutside      []string
+	trustContent      bool
+	rerunFailed       bool
+	journal           bool
+	maxAge            time.Duration
+	reverifyFraction  float64
+	renames           *renameIndex
+	trusted           *trustIndex
+	journalFile       *journal
+	interrupt         *interrupter
+	halt              *halter
+	summary           *Summary
+	failOn            string
+	failFast          bool
+	junitFile         string
+	sarifFile         string
+	htmlReportFile    string
+	outputFile        string
+	outputAppend      bool
+	outputMaxSize     int64
+	outputKeep        int
+	results           *resultsFile
+	history           bool
+	historyRun        *historyRecorder
+	statsFile         string
+	diagnosticPattern *regexp.Regexp
+	template          *template.Template
 }
 
 type Result struct {
-	Filename string `json:"filename"`
-	Hash     string `json:"hash"`
-	ExitCode int    `json:"exit_code"`
-	Audited  bool   `json:"audited,omitempty"`
-	Changed  bool   `json:"changed,omitempty"`
+	Filename    string `json:"filename"`
+	Hash        string `json:"hash"`
+	ExitCode    int    `json:"exit_code"`
+	Audited     bool   `json:"audited,omitempty"`
+	Changed     bool   `json:"changed,omitempty"`
+	Cached      bool   `json:"cached,omitempty"`
+	RenamedFrom string `json:"renamed_from,omitempty"`
+	TrustedFrom string `json:"trusted_from,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Rule        string `json:"rule,omitempty"`
+	Status      string `json:"status,omitempty"`
+
+	check     string
+	checkedAt time.Time
+	output    string
+	failed    bool
+	duration  time.Duration
 }
 
+// AuditEntry is one hashes file entry. Check identifies the commands the
+// file was checked with at CheckedAt, see checkID; their exit code is cached
+// with the check's output, stored as a blob next to the hashes file.
+// RenamedFrom is only set in the .new file of an update, where it moves the
+// entry of a renamed file.
 type AuditEntry struct {
-	Filename string `json:"filename"`
-	Hash     string `json:"hash"`
+	Filename    string    `json:"filename"`
+	Hash        string    `json:"hash"`
+	ExitCode    int       `json:"exit_code,omitempty"`
+	OutputBlob  string    `json:"output_blob,omitempty"`
+	Rule        string    `json:"rule,omitempty"`
+	Check       string    `json:"check,omitempty"`
+	CheckedAt   time.Time `json:"checked_at,omitzero"`
+	RenamedFrom string    `json:"renamed_from,omitempty"`
 }
 
 func main() {
 	cfg := parseFlags()
 
-	files := getFiles()
-	if len(files) == 0 && cfg.hashesFile == "" {
+	switch cfg.subcommand {
+	case cmdVerify:
+		os.Exit(runVerify(cfg, os.Stdout))
+	case cmdPrune:
+		os.Exit(runPrune(cfg, os.Stdout))
+	case cmdDB:
+		os.Exit(runDB(cfg, flag.Args(), os.Stdout))
+	case cmdHistory:
+		os.Exit(runHistory(cfg, os.Stdout))
+	}
+
+	run(cfg)
+}
+
+// run processes the given files, or every file in the hashes file if none
+// are given. It implements both the run and status commands.
+func run(cfg Config) {
+	given := getFiles()
+	if len(given) == 0 && cfg.hashesFile == "" {
 		fmt.Fprintln(os.Stderr, "No files to process")
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// Results and pruning aren't written over a manifest used as hashes file
+	if cfg.update || ((cfg.prune || len(cfg.pruneOutside) > 0) && !cfg.dryRun) {
+		if err := checkWritable(cfg.hashesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	// A .new file left behind by a run that was killed holds finished results
+	if cfg.update {
+		if _, err := os.Stat(cfg.hashesFile + ".new"); err == nil {
+			logError("Merging results left by an interrupted run from '%s.new'\n", cfg.hashesFile)
+			mergeHashFiles(cfg.hashesFile)
+		}
 	}
 
 	auditMap := loadAuditFile(cfg.hashesFile)
 
-	// If audit mode and no files specified, check all audit entries
-	if cfg.hashesFile != "" && len(files) == 0 {
-		for filename := range auditMap {
-			files = append(files, filename)
+	// Pruned entries are dropped before processing so they aren't re-checked,
+	// but a renamed file can still claim the entry of its deleted path
+	if cfg.prune || len(cfg.pruneOutside) > 0 {
+		cfg.renames = newRenameIndex(auditMap)
+		if err := pruneAuditMap(cfg, auditMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing hashes file: %v\n", err)
+			os.Exit(exitError)
 		}
 	}
 
+	files := filesToCheck(given, cfg, auditMap)
+
 	// Determine output writer: suppress stdout if quiet mode and hashes file are both enabled
 	var output io.Writer = os.Stdout
 	if cfg.quiet && cfg.hashesFile != "" {
 		output = io.Discard
 	}
 
-	processFiles(files, cfg, auditMap, output)
+	if cfg.outputFile != "" {
+		// A log that has grown too large is moved aside before a run appends
+		if cfg.outputMaxSize > 0 {
+			if err := rotateResultsFile(cfg.outputFile, cfg.outputMaxSize, cfg.outputKeep); err != nil {
+				fmt.Fprintf(os.Stderr, "Error rotating output file: %v\n", err)
+				os.Exit(exitError)
+			}
+		}
+		results, err := openResultsFile(cfg.outputFile, cfg.outputAppend)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening output file: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.results = results
+	}
+
+	if cfg.history && !cfg.dryRun {
+		recorder, err := startHistory(cfg.hashesFile, os.Args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting run history: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.historyRun = recorder
+	}
+
+	// SIGINT and SIGTERM stop the run early but still save its results
+	cfg.interrupt = newInterrupter(cfg.quiet)
+	defer cfg.interrupt.stop()
+
+	summary := processFiles(files, cfg, auditMap, output)
+	interrupted := cfg.interrupt.interrupted()
+	stats := summary.stats()
 
-	// Handle update mode: merge new hashes into existing file
+	// The --output file ends each run with its summary record
+	if cfg.results != nil {
+		if !cfg.dryRun {
+			if err := json.NewEncoder(cfg.results).Encode(stats); err != nil {
+				logError("Error writing to output file: %v\n", err)
+			}
+		}
+		if err := cfg.results.Close(); err != nil {
+			logError("Error writing output file: %v\n", err)
+		}
+	}
+
+	if cfg.historyRun != nil {
+		if err := cfg.historyRun.finish(stats, interrupted); err != nil {
+			logError("Error writing run history: %v\n", err)
+		}
+	}
+
+	// A status run reports its summary and fails if anything needs checking
+	if cfg.dryRun {
+		if !cfg.quiet {
+			printStatusSummary(os.Stderr, summary)
+		}
+		if interrupted {
+			os.Exit(interruptExitCode)
+		}
+		if code := summary.statusExitCode(); code != exitOK {
+			os.Exit(code)
+		}
+		return
+	}
+
+	if err := writeStats(cfg, output, stats); err != nil {
+		logError("Error writing statistics: %v\n", err)
+	}
+
+	// Handle update mode: merge new hashes into existing file. In journal
+	// mode they are already in it, but a binary file may need compacting.
 	if cfg.update {
 		mergeHashFiles(cfg.hashesFile)
+		if cfg.journal && detectFormat(cfg.hashesFile) == formatBinary {
+			if err := compactHashDBIfLarge(cfg.hashesFile); err != nil {
+				logError("Error compacting hashes file: %v\n", err)
+			}
+		}
+	}
+
+	if interrupted {
+		if !cfg.quiet {
+			logError("Interrupted after %d of %d files; results of finished checks were saved\n", summary.Processed, len(files))
+		}
+		os.Exit(interruptExitCode)
+	}
+	if code := summary.exitCode(cfg.failOn); code != exitOK {
+		if cfg.failFast && summary.Processed < len(files) && !cfg.quiet {
+			logError("Stopped by --fail-fast after %d of %d files; results of finished checks were saved\n", summary.Processed, len(files))
+		}
+		os.Exit(code)
 	}
 }
@@ -114,7 +114,7 @@ func TestWriteResultsWithUpdateMode(t *testing.T) {
 	results := make(chan *Result, 3)
 	results <- &Result{Filename: "file1.txt", Hash: "hash1", ExitCode: 0}
 	results <- &Result{Filename: "file2.txt", Hash: "hash2", ExitCode: 0} // Success - should be in .new
-	results <- &Result{Filename: "file3.txt", Hash: "hash3", ExitCode: 1} // Failure - should NOT be in .new
+	results <- &Result{Filename: "file3.txt", Hash: "hash3", ExitCode: 1} // Failure - cached in .new
 	close(results)
 
 	var buf bytes.Buffer
@@ -132,25 +132,26 @@ func TestWriteResultsWithUpdateMode(t *testing.T) {
 		t.Fatal("expected .new file to be created")
 	}
 
-	// Verify .new file contains only successful results
+	// Verify .new file contains all results
 	newEntries := loadAuditFile(newFile)
-	if len(newEntries) != 2 {
-		t.Errorf("expected 2 entries in .new file (exit 0 and 0), got %d", len(newEntries))
+	if len(newEntries) != 3 {
+		t.Errorf("expected 3 entries in .new file, got %d", len(newEntries))
 	}
 
 	// Verify file2 (exit 0) is in .new
-	if newEntries["file2.txt"] != "hash2" {
+	if newEntries["file2.txt"].Hash != "hash2" {
 		t.Errorf("expected file2.txt hash in .new file")
 	}
 
-	// Verify file3 (exit 1) is NOT in .new
-	if _, exists := newEntries["file3.txt"]; exists {
-		t.Error("file3.txt should not be in .new file (non-zero exit code)")
+	// Verify file3 (exit 1) is cached with its exit code
+	if newEntries["file3.txt"].ExitCode != 1 {
+		t.Error("file3.txt should be in .new file with exit code 1")
 	}
 }
 
 func TestWriteResultsWithUpdateModeNonZeroExit(t *testing.T) {
-	// Test that non-zero exit codes are NOT written to .new file
+	// Test that failures are cached with their exit code, but commands that
+	// could not run (-1) are not
 	tempDir, err := os.MkdirTemp("", "write_results_test")
 	if err != nil {
 		t.Fatal(err)
@@ -162,8 +163,8 @@ func TestWriteResultsWithUpdateModeNonZeroExit(t *testing.T) {
 
 	results := make(chan *Result, 4)
 	results <- &Result{Filename: "file1.txt", Hash: "hash1", ExitCode: 0}  // Should be in .new
-	results <- &Result{Filename: "file2.txt", Hash: "hash2", ExitCode: 1}  // Should NOT be in .new
-	results <- &Result{Filename: "file3.txt", Hash: "hash3", ExitCode: 2}  // Should NOT be in .new
+	results <- &Result{Filename: "file2.txt", Hash: "hash2", ExitCode: 1}  // Should be in .new
+	results <- &Result{Filename: "file3.txt", Hash: "hash3", ExitCode: -1} // Should NOT be in .new
 	results <- &Result{Filename: "file4.txt", Hash: "hash4", ExitCode: 0}  // Should be in .new
 	close(results)
 
@@ -177,26 +178,27 @@ func TestWriteResultsWithUpdateModeNonZeroExit(t *testing.T) {
 	go writeResults(results, &buf, done, cfg)
 	<-done
 
-	// Verify .new file contains only exit 0 results
+	// Verify .new file contains all results except -1
 	newEntries := loadAuditFile(newFile)
-	if len(newEntries) != 2 {
-		t.Errorf("expected 2 entries (only exit 0), got %d", len(newEntries))
+	if len(newEntries) != 3 {
+		t.Errorf("expected 3 entries (all but exit -1), got %d", len(newEntries))
 	}
 
 	// Verify correct entries are present
-	if newEntries["file1.txt"] != "hash1" {
+	if newEntries["file1.txt"].Hash != "hash1" {
 		t.Error("file1.txt (exit 0) should be in .new file")
 	}
-	if newEntries["file4.txt"] != "hash4" {
+	if newEntries["file4.txt"].Hash != "hash4" {
 		t.Error("file4.txt (exit 0) should be in .new file")
 	}
 
-	// Verify incorrect entries are absent
-	if _, exists := newEntries["file2.txt"]; exists {
-		t.Error("file2.txt (exit 1) should NOT be in .new file")
+	if newEntries["file2.txt"].ExitCode != 1 {
+		t.Error("file2.txt (exit 1) should be in .new file with its exit code")
 	}
+
+	// Verify the entry of the command that could not run is absent
 	if _, exists := newEntries["file3.txt"]; exists {
-		t.Error("file3.txt (exit 2) should NOT be in .new file")
+		t.Error("file3.txt (exit -1) should NOT be in .new file")
 	}
 }
 
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Hashes file formats besides the native JSONL. "auto" detects the format
+// when reading.
+const (
+	formatAuto      = "auto"
+	formatCoreutils = "coreutils"
+	formatBSD       = "bsd"
+	formatSidecar   = "sidecar"
+)
+
+var manifestFormats = []string{formatJSONL, formatCoreutils, formatBSD, formatSidecar}
+
+// sidecarSuffix is appended to a filename to get its sidecar hash file.
+const sidecarSuffix = ".sha256"
+
+var (
+	// "HASH  FILE" as written by sha256sum, or "HASH *FILE" in binary mode
+	coreutilsLine = regexp.MustCompile(`^\\?([0-9a-fA-F]{64}) [ *](.+)$`)
+	// "SHA256 (FILE) = HASH" as written by BSD sha256 and sha256sum --tag
+	bsdLine = regexp.MustCompile(`^\\?SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+	// A bare hash, as found in some sidecar files
+	bareHashLine = regexp.MustCompile(`^([0-9a-fA-F]{64})$`)
+)
+
+// readManifest reads the entries of a hashes file or manifest from r and
+// calls add for each. With formatAuto, JSONL input is recognized by its
+// leading '{', files named *.sha256 are read as sidecars and other lines by
+// their shape. name is the path of the manifest: filenames in a sidecar are
+// relative to its directory, and a sidecar holding only a hash applies to
+// name without its .sha256 suffix.
+func readManifest(r io.Reader, format, name string, add func(AuditEntry)) error {
+	br := bufio.NewReader(r)
+
+	if format == formatAuto {
+		first, err := firstNonSpace(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case first == '{':
+			format = formatJSONL
+		case strings.HasSuffix(name, sidecarSuffix):
+			format = formatSidecar
+		}
+	}
+
+	if format == formatJSONL {
+		decoder := json.NewDecoder(br)
+		for {
+			var entry AuditEntry
+			err := decoder.Decode(&entry)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			add(entry)
+		}
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		entry, ok := parseManifestLine(line, format, name)
+		if !ok {
+			return fmt.Errorf("line %d: not a valid %s entry: %q", lineNum, format, line)
+		}
+		add(entry)
+	}
+	return scanner.Err()
+}
+
+// parseManifestLine parses one line in the given format (or any format with
+// formatAuto).
+func parseManifestLine(line, format, name string) (AuditEntry, bool) {
+	escaped := strings.HasPrefix(line, `\`)
+
+	if format == formatCoreutils || format == formatSidecar || format == formatAuto {
+		if m := coreutilsLine.FindStringSubmatch(line); m != nil {
+			filename := m[2]
+			if escaped {
+				filename = unescapeFilename(filename)
+			}
+			if format == formatSidecar {
+				filename = filepath.Join(filepath.Dir(name), filename)
+			}
+			return AuditEntry{Filename: filename, Hash: strings.ToLower(m[1])}, true
+		}
+	}
+
+	if format == formatBSD || format == formatAuto {
+		if m := bsdLine.FindStringSubmatch(line); m != nil {
+			filename := m[1]
+			if escaped {
+				filename = unescapeFilename(filename)
+			}
+			return AuditEntry{Filename: filename, Hash: strings.ToLower(m[2])}, true
+		}
+	}
+
+	if format == formatSidecar {
+		if m := bareHashLine.FindStringSubmatch(line); m != nil {
+			return AuditEntry{Filename: strings.TrimSuffix(name, sidecarSuffix), Hash: strings.ToLower(m[1])}, true
+		}
+	}
+
+	return AuditEntry{}, false
+}
+
+// writeManifest writes entries to w in the given format. Sidecar files can't
+// be written to a single stream, see writeSidecars.
+func writeManifest(w io.Writer, format string, entries []AuditEntry) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	for _, entry := range entries {
+		var err error
+		switch format {
+		case formatJSONL:
+			err = encoder.Encode(entry)
+		case formatCoreutils:
+			_, err = fmt.Fprintf(bw, "%s%s  %s\n", escapePrefix(entry.Filename), entry.Hash, escapeFilename(entry.Filename))
+		case formatBSD:
+			_, err = fmt.Fprintf(bw, "%sSHA256 (%s) = %s\n", escapePrefix(entry.Filename), escapeFilename(entry.Filename), entry.Hash)
+		default:
+			return fmt.Errorf("cannot write %s format to a single file", format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeSidecars writes a FILE.sha256 file next to every entry's file, in the
+// format sha256sum writes from inside the file's directory. It returns the
+// paths of the sidecar files written.
+func writeSidecars(entries []AuditEntry) ([]string, error) {
+	var written []string
+	for _, entry := range entries {
+		sidecar := entry.Filename + sidecarSuffix
+		base := filepath.Base(entry.Filename)
+		content := fmt.Sprintf("%s%s  %s\n", escapePrefix(base), entry.Hash, escapeFilename(base))
+		if err := os.WriteFile(sidecar, []byte(content), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, sidecar)
+	}
+	return written, nil
+}
+
+// detectFormat returns the format of an existing hashes file judging by its
+// header or first line, or "" if it is empty or can't be read.
+func detectFormat(filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	if header, _ := br.Peek(len(hashDBMagic)); isHashDB(header) {
+		return formatBinary
+	}
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return ""
+	}
+	if first == '{' {
+		return formatJSONL
+	}
+
+	line, _ := br.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case coreutilsLine.MatchString(line):
+		return formatCoreutils
+	case bsdLine.MatchString(line):
+		return formatBSD
+	case bareHashLine.MatchString(line):
+		return formatSidecar
+	}
+	return ""
+}
+
+// firstNonSpace skips leading whitespace in br and returns the next byte
+// without consuming it.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+// Filenames containing a backslash or newline are escaped by coreutils, and
+// the line is then prefixed with a backslash.
+var (
+	filenameEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	filenameUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
+
+func escapePrefix(filename string) string {
+	if strings.ContainsAny(filename, "\\\n\r") {
+		return `\`
+	}
+	return ""
+}
+
+func escapeFilename(filename string) string {
+	if escapePrefix(filename) == "" {
+		return filename
+	}
+	return filenameEscaper.Replace(filename)
+}
+
+func unescapeFilename(filename string) string {
+	return filenameUnescaper.Replace(filename)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testHash1 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	testHash2 = "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+)
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		manifest string
+		input    string
+		expected []AuditEntry
+	}{
+		{
+			name:     "jsonl",
+			format:   formatAuto,
+			manifest: "hashes.jsonl",
+			input:    `{"filename":"a.txt","hash":"` + testHash1 + `"}` + "\n",
+			expected: []AuditEntry{{Filename: "a.txt", Hash: testHash1}},
+		},
+		{
+			name:     "coreutils text and binary mode",
+			format:   formatAuto,
+			manifest: "SHA256SUMS",
+			input:    testHash1 + "  a.txt\n" + strings.ToUpper(testHash2) + " *dir/b.bin\n",
+			expected: []AuditEntry{{Filename: "a.txt", Hash: testHash1}, {Filename: "dir/b.bin", Hash: testHash2}},
+		},
+		{
+			name:     "coreutils escaped filename",
+			format:   formatCoreutils,
+			manifest: "SHA256SUMS",
+			input:    `\` + testHash1 + `  new\nline\\name` + "\n",
+			expected: []AuditEntry{{Filename: "new\nline\\name", Hash: testHash1}},
+		},
+		{
+			name:     "bsd",
+			format:   formatAuto,
+			manifest: "CHECKSUMS",
+			input:    "SHA256 (a (1).txt) = " + testHash1 + "\r\n\n",
+			expected: []AuditEntry{{Filename: "a (1).txt", Hash: testHash1}},
+		},
+		{
+			name:     "sidecar with filename",
+			format:   formatAuto,
+			manifest: "dir/a.txt.sha256",
+			input:    testHash1 + "  a.txt\n",
+			expected: []AuditEntry{{Filename: "dir/a.txt", Hash: testHash1}},
+		},
+		{
+			name:     "sidecar with bare hash",
+			format:   formatSidecar,
+			manifest: "dir/a.txt.sha256",
+			input:    testHash1 + "\n",
+			expected: []AuditEntry{{Filename: "dir/a.txt", Hash: testHash1}},
+		},
+		{
+			name:     "empty",
+			format:   formatAuto,
+			manifest: "SHA256SUMS",
+			input:    "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries []AuditEntry
+			err := readManifest(strings.NewReader(tt.input), tt.format, tt.manifest, func(entry AuditEntry) {
+				entries = append(entries, entry)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(entries, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, entries)
+			}
+		})
+	}
+}
+
+func TestReadManifest_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{"unrecognized line", formatAuto, testHash1 + "  a.txt\nnot a hash line\n"},
+		{"bsd line read as coreutils", formatCoreutils, "SHA256 (a.txt) = " + testHash1 + "\n"},
+		{"short hash", formatCoreutils, "abc123  a.txt\n"},
+		{"bare hash outside sidecar", formatAuto, testHash1 + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := readManifest(strings.NewReader(tt.input), tt.format, "SHA256SUMS", func(AuditEntry) {})
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestWriteManifest_RoundTrip(t *testing.T) {
+	entries := []AuditEntry{
+		{Filename: "a.txt", Hash: testHash1},
+		{Filename: "odd\\name\n.txt", Hash: testHash2},
+	}
+
+	for _, format := range []string{formatJSONL, formatCoreutils, formatBSD} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeManifest(&buf, format, entries); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var read []AuditEntry
+			err := readManifest(&buf, formatAuto, "manifest", func(entry AuditEntry) {
+				read = append(read, entry)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error reading back: %v", err)
+			}
+			if !reflect.DeepEqual(read, entries) {
+				t.Errorf("expected %+v, got %+v", entries, read)
+			}
+		})
+	}
+
+	if err := writeManifest(&bytes.Buffer{}, formatSidecar, entries); err == nil {
+		t.Error("expected error writing sidecar format to a single file")
+	}
+}
+
+func TestWriteSidecars(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "a.txt")
+
+	written, err := writeSidecars([]AuditEntry{{Filename: file, Hash: testHash1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(written) != 1 || written[0] != file+sidecarSuffix {
+		t.Fatalf("expected %s to be written, got %v", file+sidecarSuffix, written)
+	}
+
+	content, err := os.ReadFile(written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != testHash1+"  a.txt\n" {
+		t.Errorf("unexpected sidecar content %q", content)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{`{"filename":"a.txt","hash":"` + testHash1 + `"}`, formatJSONL},
+		{testHash1 + "  a.txt", formatCoreutils},
+		{"SHA256 (a.txt) = " + testHash1, formatBSD},
+		{testHash1, formatSidecar},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(tempDir, "hashes")
+		if err := os.WriteFile(path, []byte(tt.content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := detectFormat(path); got != tt.expected {
+			t.Errorf("detectFormat(%q) = %q, expected %q", tt.content, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadAuditFile_Manifest(t *testing.T) {
+	tempDir := t.TempDir()
+	manifest := filepath.Join(tempDir, "SHA256SUMS")
+	content := testHash1 + "  a.txt\n" + testHash2 + "  b.txt\n"
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	auditMap := loadAuditFile(manifest)
+	if len(auditMap) != 2 || auditMap["a.txt"].Hash != testHash1 || auditMap["b.txt"].Hash != testHash2 {
+		t.Errorf("unexpected entries from manifest: %+v", auditMap)
+	}
+
+	// The manifest isn't replaced with JSONL unless converted explicitly
+	if err := writeAuditFile(manifest, auditMap); err == nil {
+		t.Error("expected an error writing to a manifest")
+	}
+	if data, _ := os.ReadFile(manifest); string(data) != content {
+		t.Errorf("expected manifest to be left alone, got:\n%s", data)
+	}
+	if code := runDB(Config{hashesFile: manifest, dbAction: "convert", format: formatJSONL}, nil, io.Discard); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if detectFormat(manifest) != formatJSONL || len(loadAuditFile(manifest)) != 2 {
+		t.Error("expected db convert to turn the manifest into JSONL")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resultsFile is the --output file, which gets the JSONL results of a run
+// whatever the --format on stdout. A name ending in .gz is compressed with
+// gzip; appended runs are separate gzip members, which gzip tools and
+// readers concatenate.
+type resultsFile struct {
+	file *os.File
+	gz   *gzip.Writer
+}
+
+// openResultsFile opens path for the results of a run, replacing it unless
+// appending.
+func openResultsFile(path string, appending bool) (*resultsFile, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	out := &resultsFile{file: f}
+	if strings.HasSuffix(path, ".gz") {
+		out.gz = gzip.NewWriter(f)
+	}
+	return out, nil
+}
+
+func (o *resultsFile) Write(p []byte) (int, error) {
+	if o.gz != nil {
+		return o.gz.Write(p)
+	}
+	return o.file.Write(p)
+}
+
+// Close flushes the compressed stream, if any, and closes the file.
+func (o *resultsFile) Close() error {
+	if o.gz != nil {
+		if err := o.gz.Close(); err != nil {
+			o.file.Close()
+			return err
+		}
+	}
+	return o.file.Close()
+}
+
+// parseSize parses an --output-max-size: a number of bytes, optionally with
+// a K, M or G suffix for KiB, MiB or GiB.
+func parseSize(s string) (int64, error) {
+	number, multiplier := strings.ToUpper(s), int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if n, ok := strings.CutSuffix(number, suffix); ok {
+			number, multiplier = n, 1<<(10*(i+1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return n * multiplier, nil
+}
+
+// rotatedName returns the name of the nth rotated copy of the --output file
+// path: results.jsonl.1, or results.jsonl.1.gz for a compressed one.
+func rotatedName(path string, n int) string {
+	if base, ok := strings.CutSuffix(path, ".gz"); ok {
+		return fmt.Sprintf("%s.%d.gz", base, n)
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateResultsFile moves path aside once it has grown to maxSize bytes, so
+// the run starts a new one. The rotated copies are shifted up by one, and
+// the oldest is removed once there are keep of them.
+func rotateResultsFile(path string, maxSize int64, keep int) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < maxSize {
+		return nil
+	}
+	if keep == 0 {
+		return os.Remove(path)
+	}
+
+	if err := os.Remove(rotatedName(path, keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := keep - 1; n > 0; n-- {
+		if err := os.Rename(rotatedName(path, n), rotatedName(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, rotatedName(path, 1))
+}
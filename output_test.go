@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readResultsFile returns the JSONL records of an --output file,
+// decompressing it if it is gzipped.
+func readResultsFile(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestResultsFile(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		output    string
+		appending bool
+		want      int
+	}{
+		{"plain", "results.jsonl", false, 1},
+		{"plain append", "appended.jsonl", true, 2},
+		{"gzip", "results.jsonl.gz", false, 1},
+		{"gzip append", "appended.jsonl.gz", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.output)
+			for range 2 {
+				results, err := openResultsFile(path, tt.appending)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The results file is written whatever the format on stdout
+				cfg := Config{command: "true", format: formatText, workers: 1, quiet: true, results: results}
+				processFiles([]string{file}, cfg, nil, io.Discard)
+				if err := results.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records := readResultsFile(t, path)
+			if len(records) != tt.want {
+				t.Fatalf("expected %d results, got %v", tt.want, records)
+			}
+			for _, record := range records {
+				if record["filename"] != file || record["exit_code"] != 0.0 {
+					t.Errorf("unexpected result %v", record)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateResultsFile(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "results.jsonl.gz")
+	write := func(name, content string) {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	// Nothing to rotate yet
+	if err := rotateResultsFile(path, 4, 2); err != nil {
+		t.Fatal(err)
+	}
+	write(path, "abc")
+	if err := rotateResultsFile(path, 4, 2); err != nil || read(path) != "abc" {
+		t.Fatalf("expected a file below the size to be kept, got %q (%v)", read(path), err)
+	}
+
+	for _, content := range []string{"run1", "run2", "run3"} {
+		write(path, content)
+		if err := rotateResultsFile(path, 4, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be moved aside, got %v", path, err)
+		}
+	}
+	first, second := filepath.Join(tempDir, "results.jsonl.1.gz"), filepath.Join(tempDir, "results.jsonl.2.gz")
+	if read(first) != "run3" || read(second) != "run2" {
+		t.Errorf("expected the 2 newest copies, got %q and %q", read(first), read(second))
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "results.jsonl.3.gz")); !os.IsNotExist(err) {
+		t.Errorf("expected the oldest copy to be removed, got %v", err)
+	}
+
+	write(path, "run4")
+	if err := rotateResultsFile(path, 4, 0); err != nil || read(path) != "" || read(first) != "run3" {
+		t.Errorf("expected the file to be removed when keeping none, got %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"512", 512},
+		{"10K", 10 << 10},
+		{"100m", 100 << 20},
+		{"2G", 2 << 30},
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.input); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+		}
+	}
+	for _, input := range []string{"", "0", "-1K", "10X", "M"} {
+		if _, err := parseSize(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchPattern reports whether name matches the glob pattern. Patterns without
+// a slash are matched against the base name only, so "*.go" matches Go files
+// at any depth. Patterns with a slash are matched against the whole path and
+// may use "**" to match any number of directories.
+func matchPattern(pattern, name string) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFiles keeps files matching at least one include pattern (or all files
+// if there are none) and drops files matching any exclude pattern.
+func filterFiles(files, include, exclude []string) []string {
+	if len(include) == 0 && len(exclude) == 0 {
+		return files
+	}
+
+	var filtered []string
+	for _, file := range files {
+		if len(include) > 0 && !matchAny(include, file) {
+			continue
+		}
+		if matchAny(exclude, file) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return filtered
+}
+
+// filesToCheck returns the given files that selectFiles keeps. If no files
+// were given at all, the files in the hashes file are checked instead; given
+// files that are all filtered out leave nothing to check.
+func filesToCheck(given []string, cfg Config, auditMap map[string]AuditEntry) []string {
+	if len(given) == 0 && cfg.hashesFile != "" {
+		for filename := range auditMap {
+			given = append(given, filename)
+		}
+	}
+	return selectFiles(given, cfg)
+}
+
+func parsePatterns(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var patterns []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			patterns = append(patterns, part)
+		}
+	}
+	return patterns
+}
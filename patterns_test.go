@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "src/pkg/main.go", true},
+		{"*.go", "./main.go", true},
+		{"*.go", "main.sh", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/pkg/sub/main.go", true},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/b.go", false},
+		{"**/testdata/**", "pkg/testdata/in.json", true},
+		{"/src/*.go", "src/main.go", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := matchPattern(tt.pattern, tt.name); got != tt.expected {
+				t.Errorf("matchPattern(%q, %q) = %v, expected %v", tt.pattern, tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterFiles(t *testing.T) {
+	files := []string{"main.go", "main_test.go", "vendor/lib/lib.go", "run.sh"}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:     "no patterns",
+			expected: files,
+		},
+		{
+			name:     "include only",
+			include:  []string{"*.go"},
+			expected: []string{"main.go", "main_test.go", "vendor/lib/lib.go"},
+		},
+		{
+			name:     "exclude only",
+			exclude:  []string{"vendor/**", "*_test.go"},
+			expected: []string{"main.go", "run.sh"},
+		},
+		{
+			name:     "include and exclude",
+			include:  []string{"*.go"},
+			exclude:  []string{"vendor/**"},
+			expected: []string{"main.go", "main_test.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterFiles(files, tt.include, tt.exclude)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFilesToCheck(t *testing.T) {
+	cfg := Config{hashesFile: "hashes.jsonl", exclude: []string{"*.md"}}
+	auditMap := map[string]AuditEntry{
+		"main.go":   {Filename: "main.go"},
+		"README.md": {Filename: "README.md"},
+	}
+
+	if files := filesToCheck(nil, cfg, auditMap); !reflect.DeepEqual(files, []string{"main.go"}) {
+		t.Errorf("expected the filtered hashes file entries without files given, got %v", files)
+	}
+
+	// Excluding every given file must not fall back to the hashes file
+	if files := filesToCheck([]string{"CHANGELOG.md"}, cfg, auditMap); len(files) != 0 {
+		t.Errorf("expected no files to check, got %v", files)
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	if result := parsePatterns(""); result != nil {
+		t.Errorf("expected nil, got %v", result)
+	}
+
+	result := parsePatterns(" *.go , ,vendor/** ")
+	expected := []string{"*.go", "vendor/**"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killGroupOnCancel leaves cancelling cmd to kill only the shell on
+// platforms without process groups; its WaitDelay bounds the wait for the
+// commands the shell started.
+func killGroupOnCancel(cmd *exec.Cmd) {}
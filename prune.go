@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// runPrune removes hashes file entries whose files no longer exist, or that
+// are outside the --prune-outside paths, and prints the removed entries as
+// JSONL. Cached output no entry refers to anymore is deleted as well. It
+// returns the process exit code.
+func runPrune(cfg Config, output io.Writer) int {
+	auditMap := loadAuditFile(cfg.hashesFile)
+	removed := pruneMissing(auditMap)
+	if len(cfg.pruneOutside) > 0 {
+		removed = append(removed, pruneOutside(auditMap, cfg.pruneOutside)...)
+	}
+
+	if len(removed) > 0 {
+		if err := writeAuditFile(cfg.hashesFile, auditMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing hashes file: %v\n", err)
+			return exitError
+		}
+	}
+
+	encoder := json.NewEncoder(output)
+	for _, entry := range removed {
+		if err := encoder.Encode(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding entry: %v\n", err)
+			return exitError
+		}
+	}
+
+	blobs, err := pruneBlobs(blobDir(cfg.hashesFile), auditMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning cached output: %v\n", err)
+		return exitError
+	}
+
+	if !cfg.quiet {
+		fmt.Fprintf(os.Stderr, "Pruned %d of %d entries\n", len(removed), len(removed)+len(auditMap))
+		if blobs > 0 {
+			fmt.Fprintf(os.Stderr, "Removed %d unused cached outputs\n", blobs)
+		}
+	}
+	return 0
+}
+
+// pruneMissing deletes the entries of files that no longer exist from
+// auditMap and returns them sorted by filename.
+func pruneMissing(auditMap map[string]AuditEntry) []AuditEntry {
+	var removed []AuditEntry
+	for _, name := range slices.Sorted(maps.Keys(auditMap)) {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			removed = append(removed, auditMap[name])
+			delete(auditMap, name)
+		}
+	}
+	return removed
+}
+
+// pruneOutside deletes the entries of files that are not within any of paths
+// from auditMap and returns them sorted by filename.
+func pruneOutside(auditMap map[string]AuditEntry, paths []string) []AuditEntry {
+	var removed []AuditEntry
+	for _, name := range slices.Sorted(maps.Keys(auditMap)) {
+		if !withinAny(name, paths) {
+			removed = append(removed, auditMap[name])
+			delete(auditMap, name)
+		}
+	}
+	return removed
+}
+
+// withinAny reports whether name is one of paths or inside one of them.
+// Relative names and paths are resolved against the working directory.
+func withinAny(name string, paths []string) bool {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return true // keep what can't be resolved
+	}
+	for _, path := range paths {
+		root, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneAuditMap implements --prune and --prune-outside for run and status:
+// it drops the entries from auditMap, reports each on stderr and, unless
+// this is a dry run, writes the hashes file without them.
+func pruneAuditMap(cfg Config, auditMap map[string]AuditEntry) error {
+	total := len(auditMap)
+	verb := "Pruned"
+	if cfg.dryRun {
+		verb = "Would prune"
+	}
+
+	var removed []AuditEntry
+	if cfg.prune {
+		for _, entry := range pruneMissing(auditMap) {
+			removed = append(removed, entry)
+			if !cfg.quiet {
+				fmt.Fprintf(os.Stderr, "%s %s (missing)\n", verb, entry.Filename)
+			}
+		}
+	}
+	if len(cfg.pruneOutside) > 0 {
+		for _, entry := range pruneOutside(auditMap, cfg.pruneOutside) {
+			removed = append(removed, entry)
+			if !cfg.quiet {
+				fmt.Fprintf(os.Stderr, "%s %s (outside %s)\n", verb, entry.Filename, strings.Join(cfg.pruneOutside, ", "))
+			}
+		}
+	}
+
+	if !cfg.quiet {
+		fmt.Fprintf(os.Stderr, "%s %d of %d entries\n", verb, len(removed), total)
+	}
+
+	if len(removed) == 0 || cfg.dryRun {
+		return nil
+	}
+	return writeAuditFile(cfg.hashesFile, auditMap)
+}
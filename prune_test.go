@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPruneMissing(t *testing.T) {
+	tempDir := t.TempDir()
+	kept := filepath.Join(tempDir, "kept.txt")
+	if err := os.WriteFile(kept, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(tempDir, "gone.txt")
+
+	auditMap := map[string]AuditEntry{
+		kept: {Filename: kept, Hash: "hash1"},
+		gone: {Filename: gone, Hash: "hash2"},
+	}
+
+	removed := pruneMissing(auditMap)
+	if len(removed) != 1 || removed[0].Filename != gone {
+		t.Errorf("expected %s to be removed, got %+v", gone, removed)
+	}
+	if _, exists := auditMap[kept]; !exists || len(auditMap) != 1 {
+		t.Errorf("expected only %s to remain, got %+v", kept, auditMap)
+	}
+}
+
+func TestRunPrune(t *testing.T) {
+	tempDir := t.TempDir()
+	kept := filepath.Join(tempDir, "kept.txt")
+	if err := os.WriteFile(kept, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(tempDir, "gone.txt")
+
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	entries := map[string]AuditEntry{
+		kept: {Filename: kept, Hash: "hash1"},
+		gone: {Filename: gone, Hash: "hash2"},
+	}
+	if err := writeAuditFile(hashesFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if exitCode := runPrune(Config{hashesFile: hashesFile, quiet: true}, &buf); exitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", exitCode)
+	}
+
+	var reported AuditEntry
+	if err := json.Unmarshal(buf.Bytes(), &reported); err != nil {
+		t.Fatalf("expected removed entry as JSON: %v", err)
+	}
+	if reported.Filename != gone {
+		t.Errorf("expected %s to be reported, got %s", gone, reported.Filename)
+	}
+
+	result := loadAuditFile(hashesFile)
+	if len(result) != 1 || result[kept].Hash != "hash1" {
+		t.Errorf("expected only %s in hashes file, got %+v", kept, result)
+	}
+}
+
+func TestWithinAny(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name     string
+		paths    []string
+		expected bool
+	}{
+		{"src/main.go", []string{"src"}, true},
+		{"./src/pkg/util.go", []string{"src/"}, true},
+		{"src", []string{"src"}, true},
+		{"srcx/main.go", []string{"src"}, false},
+		{"../other/main.go", []string{"."}, false},
+		{"lib/a.go", []string{"src", "lib"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinAny(tt.name, tt.paths); got != tt.expected {
+				t.Errorf("withinAny(%q, %v) = %v, expected %v", tt.name, tt.paths, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPruneOutside(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	auditMap := map[string]AuditEntry{
+		"src/a.go":    {Filename: "src/a.go", Hash: "hash1"},
+		"vendor/b.go": {Filename: "vendor/b.go", Hash: "hash2"},
+		"c.go":        {Filename: "c.go", Hash: "hash3"},
+	}
+
+	removed := pruneOutside(auditMap, []string{"src"})
+	if len(removed) != 2 || removed[0].Filename != "c.go" || removed[1].Filename != "vendor/b.go" {
+		t.Errorf("expected c.go and vendor/b.go to be removed, got %+v", removed)
+	}
+	if _, exists := auditMap["src/a.go"]; !exists || len(auditMap) != 1 {
+		t.Errorf("expected only src/a.go to remain, got %+v", auditMap)
+	}
+}
+
+func TestPruneAuditMap(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Chdir(tempDir)
+	if err := os.Mkdir("src", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("src/kept.go", []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("outside.go", []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	entries := map[string]AuditEntry{
+		"src/kept.go": {Filename: "src/kept.go", Hash: "hash1"},
+		"src/gone.go": {Filename: "src/gone.go", Hash: "hash2"},
+		"outside.go":  {Filename: "outside.go", Hash: "hash3"},
+	}
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		remaining int
+		stored    int
+	}{
+		{"dry run keeps hashes file", Config{prune: true, pruneOutside: []string{"src"}, dryRun: true}, 1, 3},
+		{"prune missing", Config{prune: true}, 2, 2},
+		{"prune missing and outside", Config{prune: true, pruneOutside: []string{"src"}}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeAuditFile(hashesFile, entries); err != nil {
+				t.Fatal(err)
+			}
+			auditMap := loadAuditFile(hashesFile)
+
+			tt.cfg.hashesFile = hashesFile
+			tt.cfg.quiet = true
+			if err := pruneAuditMap(tt.cfg, auditMap); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(auditMap) != tt.remaining {
+				t.Errorf("expected %d entries in memory, got %+v", tt.remaining, auditMap)
+			}
+			if stored := loadAuditFile(hashesFile); len(stored) != tt.stored {
+				t.Errorf("expected %d entries in hashes file, got %+v", tt.stored, stored)
+			}
+			if _, exists := auditMap["src/kept.go"]; !exists {
+				t.Error("expected src/kept.go to be kept")
+			}
+			for name := range auditMap {
+				if strings.Contains(name, "gone") {
+					t.Errorf("expected %s to be pruned", name)
+				}
+			}
+		})
+	}
+}
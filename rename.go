@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// renameIndex looks up hashes file entries by hash to detect files that were
+// renamed or moved without edits. It is safe for concurrent use by the
+// workers.
+type renameIndex struct {
+	mu      sync.Mutex
+	byHash  map[string][]AuditEntry
+	claimed map[string]bool
+}
+
+func newRenameIndex(auditMap map[string]AuditEntry) *renameIndex {
+	ri := &renameIndex{
+		byHash:  make(map[string][]AuditEntry),
+		claimed: make(map[string]bool),
+	}
+	for name, entry := range auditMap {
+		entry.Filename = name
+		ri.byHash[entry.Hash] = append(ri.byHash[entry.Hash], entry)
+	}
+	return ri
+}
+
+// claim returns a stored entry with the given hash whose file no longer
+// exists, so a new file with that hash is its renamed version. Each stored
+// entry is claimed at most once, so of several copies only one is a rename.
+// The index keeps the entries it was built from, so they can be claimed
+// after --prune has dropped them from the hashes file.
+func (ri *renameIndex) claim(hash string) (AuditEntry, bool) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	for _, entry := range ri.byHash[hash] {
+		if ri.claimed[entry.Filename] {
+			continue
+		}
+		if _, err := os.Stat(entry.Filename); !os.IsNotExist(err) {
+			continue
+		}
+		ri.claimed[entry.Filename] = true
+		return entry, true
+	}
+	return AuditEntry{}, false
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameIndexClaim(t *testing.T) {
+	tempDir := t.TempDir()
+	existing := filepath.Join(tempDir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(tempDir, "gone.txt")
+
+	ri := newRenameIndex(map[string]AuditEntry{
+		existing: {Filename: existing, Hash: "hash1"},
+		gone:     {Filename: gone, Hash: "hash2"},
+	})
+
+	if _, ok := ri.claim("hash1"); ok {
+		t.Error("expected an existing file not to be claimed as renamed")
+	}
+	if from, ok := ri.claim("hash2"); !ok || from.Filename != gone {
+		t.Errorf("expected %s to be claimed, got %+v", gone, from)
+	}
+	if _, ok := ri.claim("hash2"); ok {
+		t.Error("expected an entry to be claimed only once")
+	}
+	if _, ok := ri.claim("unknown"); ok {
+		t.Error("expected unknown hash not to be claimed")
+	}
+}
+
+func TestProcessFileRename(t *testing.T) {
+	tempDir := t.TempDir()
+	oldPath := filepath.Join(tempDir, "old.txt")
+	newPath := filepath.Join(tempDir, "new.txt")
+	if err := os.WriteFile(newPath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditMap := map[string]AuditEntry{oldPath: {Filename: oldPath, Hash: hash, Rule: "lint"}}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		status string
+		rule   string
+	}{
+		// The command would fail, so a zero exit code shows it never ran
+		{"audit", Config{command: "false", audit: true, quiet: true}, "", "lint"},
+		{"dry run", Config{command: "false", dryRun: true, quiet: true}, statusRenamed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.renames = newRenameIndex(auditMap)
+			result := processFile(newPath, tt.cfg, auditMap)
+			if result == nil {
+				t.Fatal("expected result")
+			}
+			if result.RenamedFrom != oldPath || !result.Audited || result.Changed {
+				t.Errorf("expected unchanged rename from %s, got %+v", oldPath, result)
+			}
+			if result.ExitCode != 0 {
+				t.Errorf("expected command not to run, got exit code %d", result.ExitCode)
+			}
+			if result.Rule != tt.rule {
+				t.Errorf("expected rule %q, got %q", tt.rule, result.Rule)
+			}
+			if result.Status != tt.status {
+				t.Errorf("expected status %q, got %q", tt.status, result.Status)
+			}
+		})
+	}
+}
+
+func TestProcessFilesRenameUpdate(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	oldPath := filepath.Join(tempDir, "old.txt")
+	newPath := filepath.Join(tempDir, "new.txt")
+	copyPath := filepath.Join(tempDir, "copy.txt")
+	for _, f := range []string{newPath, copyPath} {
+		if err := os.WriteFile(f, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuditFile(hashesFile, map[string]AuditEntry{oldPath: {Filename: oldPath, Hash: hash}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{command: "false", audit: true, update: true, hashesFile: hashesFile, workers: 2, quiet: true}
+	var buf bytes.Buffer
+	processFiles([]string{newPath, copyPath}, cfg, loadAuditFile(hashesFile), &buf)
+	mergeHashFiles(hashesFile)
+
+	// Only one of the two identical files is the renamed one
+	renamed := 0
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var result Result
+		if err := decoder.Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result.RenamedFrom == oldPath {
+			renamed++
+		}
+	}
+	if renamed != 1 {
+		t.Errorf("expected exactly one rename, got %d", renamed)
+	}
+
+	result := loadAuditFile(hashesFile)
+	if _, exists := result[oldPath]; exists {
+		t.Error("expected old path to be removed from hashes file")
+	}
+	if _, exists := result[newPath]; !exists {
+		t.Error("expected new path in hashes file")
+	}
+	for _, entry := range result {
+		if entry.RenamedFrom != "" {
+			t.Errorf("expected renamed_from not to be stored, got %+v", entry)
+		}
+	}
+}
+
+func TestProcessFilesRenameAfterPrune(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	oldPath := filepath.Join(tempDir, "old.txt")
+	newPath := filepath.Join(tempDir, "new.txt")
+	if err := os.WriteFile(newPath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAuditFile(hashesFile, map[string]AuditEntry{oldPath: {Filename: oldPath, Hash: hash, Rule: "lint"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// As in run: the rename index is built before --prune drops old.txt
+	cfg := Config{command: "false", audit: true, update: true, prune: true, hashesFile: hashesFile, workers: 1, quiet: true}
+	auditMap := loadAuditFile(hashesFile)
+	cfg.renames = newRenameIndex(auditMap)
+	if err := pruneAuditMap(cfg, auditMap); err != nil {
+		t.Fatal(err)
+	}
+	if len(auditMap) != 0 {
+		t.Fatalf("expected old.txt to be pruned, got %+v", auditMap)
+	}
+
+	var buf bytes.Buffer
+	processFiles([]string{newPath}, cfg, auditMap, &buf)
+	mergeHashFiles(hashesFile)
+
+	var result Result
+	if err := json.NewDecoder(&buf).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.RenamedFrom != oldPath || !result.Cached || result.ExitCode != 0 || result.Rule != "lint" {
+		t.Errorf("expected the rename to keep the verification of %s, got %+v", oldPath, result)
+	}
+	if stored := loadAuditFile(hashesFile); len(stored) != 1 || stored[newPath].Rule != "lint" {
+		t.Errorf("expected only new.txt in hashes file, got %+v", stored)
+	}
+}
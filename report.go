@@ -0,0 +1,35 @@
+package main
+
+// report collects the results of a run into a file written once all
+// results are in, such as a JUnit, SARIF or HTML report.
+type report interface {
+	add(result *Result)
+	write() error
+}
+
+// newReports returns the reports requested by cfg.
+func newReports(cfg Config) []report {
+	var reports []report
+	if cfg.junitFile != "" {
+		reports = append(reports, newJUnitReport(cfg))
+	}
+	if cfg.sarifFile != "" {
+		reports = append(reports, newSARIFReport(cfg))
+	}
+	if cfg.htmlReportFile != "" {
+		reports = append(reports, newHTMLReport(cfg))
+	}
+	return reports
+}
+
+// checkName names the check that produced result in reports: its rules, or
+// the command if no rule is named.
+func checkName(cfg Config, result *Result) string {
+	switch {
+	case result.Rule != "":
+		return result.Rule
+	case cfg.command != "":
+		return cfg.command
+	}
+	return "ghc"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rule matching modes for --rule-mode.
+const (
+	ruleModeFirst = "first"
+	ruleModeAll   = "all"
+)
+
+// Handling of files that match no rule for --unmatched.
+const (
+	unmatchedDefault = "default"
+	unmatchedSkip    = "skip"
+)
+
+// Rule maps files matching any of its patterns to a command. Exit-code filters
+// and the timeout replace the global ones for files handled by the rule.
+type Rule struct {
+	Name             string   `yaml:"name" json:"name"`
+	Patterns         []string `yaml:"patterns" json:"patterns"`
+	Command          string   `yaml:"command" json:"command"`
+	SuccessExitCodes []int    `yaml:"success_exit_codes" json:"success_exit_codes"`
+	ErrorExitCodes   []int    `yaml:"error_exit_codes" json:"error_exit_codes"`
+	Timeout          string   `yaml:"timeout" json:"timeout"`
+
+	timeout time.Duration
+}
+
+// validateRules checks every rule, parses its timeout and names unnamed
+// rules after their command.
+func validateRules(rules []Rule) error {
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.Patterns) == 0 {
+			return fmt.Errorf("rule %d has no patterns", i+1)
+		}
+		if rule.Command == "" {
+			return fmt.Errorf("rule %d has no command", i+1)
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Command
+		}
+		if rule.Timeout != "" {
+			timeout, err := time.ParseDuration(rule.Timeout)
+			if err != nil {
+				return fmt.Errorf("rule '%s' has invalid timeout '%s': %w", rule.Name, rule.Timeout, err)
+			}
+			rule.timeout = timeout
+		}
+	}
+	return nil
+}
+
+// rulesFor returns the rules to apply to filename: the first matching rule,
+// or every matching rule with --rule-mode all. Files matching no rule get an
+// unnamed rule running the default check command, or nil with --unmatched skip.
+func rulesFor(cfg Config, filename string) []Rule {
+	var matched []Rule
+	for _, rule := range cfg.rules {
+		if !matchAny(rule.Patterns, filename) {
+			continue
+		}
+		matched = append(matched, rule)
+		if cfg.ruleMode != ruleModeAll {
+			break
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched
+	}
+	if cfg.unmatched == unmatchedSkip {
+		return nil
+	}
+	return []Rule{{Command: cfg.command}}
+}
+
+// apply returns cfg with the command, timeout and exit-code filters of the rule.
+func (r Rule) apply(cfg Config) Config {
+	cfg.command = r.Command
+	if r.timeout > 0 {
+		cfg.timeout = r.timeout
+	}
+	if r.SuccessExitCodes != nil || r.ErrorExitCodes != nil {
+		cfg.successCodes = exitCodeSet(r.SuccessExitCodes)
+		cfg.errorCodes = exitCodeSet(r.ErrorExitCodes)
+		cfg.filterOnCodes = true
+	}
+	return cfg
+}
+
+// selectFiles applies the include and exclude patterns and, with --unmatched
+// skip, drops files that no rule applies to.
+func selectFiles(files []string, cfg Config) []string {
+	files = filterFiles(files, cfg.include, cfg.exclude)
+	if cfg.unmatched != unmatchedSkip {
+		return files
+	}
+
+	var routed []string
+	for _, file := range files {
+		if rulesFor(cfg, file) != nil {
+			routed = append(routed, file)
+		}
+	}
+	return routed
+}
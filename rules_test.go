@@ -0,0 +1,240 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateRules(t *testing.T) {
+	rules := []Rule{
+		{Patterns: []string{"*.go"}, Command: "gofmt -l", Timeout: "10s"},
+		{Name: "shell", Patterns: []string{"*.sh"}, Command: "shellcheck"},
+	}
+	if err := validateRules(rules); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rules[0].Name != "gofmt -l" {
+		t.Errorf("expected unnamed rule to be named after its command, got %q", rules[0].Name)
+	}
+	if rules[0].timeout != 10*time.Second {
+		t.Errorf("expected parsed timeout 10s, got %s", rules[0].timeout)
+	}
+	if rules[1].Name != "shell" {
+		t.Errorf("expected explicit name to be kept, got %q", rules[1].Name)
+	}
+
+	invalid := [][]Rule{
+		{{Command: "true"}},
+		{{Patterns: []string{"*.go"}}},
+		{{Patterns: []string{"*.go"}, Command: "true", Timeout: "later"}},
+	}
+	for _, rules := range invalid {
+		if err := validateRules(rules); err == nil {
+			t.Errorf("expected error for %+v", rules)
+		}
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	cfg := Config{
+		command: "default-check",
+		rules: []Rule{
+			{Name: "fmt", Patterns: []string{"*.go"}, Command: "gofmt -l"},
+			{Name: "shell", Patterns: []string{"*.sh", "*.bash"}, Command: "shellcheck"},
+			{Name: "vet", Patterns: []string{"*.go"}, Command: "go vet"},
+		},
+		ruleMode:  ruleModeFirst,
+		unmatched: unmatchedDefault,
+	}
+
+	names := func(rules []Rule) []string {
+		var result []string
+		for _, rule := range rules {
+			result = append(result, rule.Name+"="+rule.Command)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		ruleMode  string
+		unmatched string
+		filename  string
+		expected  []string
+	}{
+		{"first match", ruleModeFirst, unmatchedDefault, "src/main.go", []string{"fmt=gofmt -l"}},
+		{"second pattern", ruleModeFirst, unmatchedDefault, "a.bash", []string{"shell=shellcheck"}},
+		{"all matches", ruleModeAll, unmatchedDefault, "src/main.go", []string{"fmt=gofmt -l", "vet=go vet"}},
+		{"unmatched default", ruleModeFirst, unmatchedDefault, "data.json", []string{"=default-check"}},
+		{"unmatched skip", ruleModeFirst, unmatchedSkip, "data.json", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.ruleMode = tt.ruleMode
+			cfg.unmatched = tt.unmatched
+			result := names(rulesFor(cfg, tt.filename))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRuleApply(t *testing.T) {
+	cfg := Config{
+		command:       "global",
+		timeout:       time.Minute,
+		successCodes:  map[int]bool{0: true},
+		filterOnCodes: true,
+	}
+
+	unchanged := Rule{Command: "rule-cmd"}.apply(cfg)
+	if unchanged.command != "rule-cmd" || unchanged.timeout != time.Minute || !unchanged.successCodes[0] {
+		t.Errorf("expected global timeout and filters to be kept: %+v", unchanged)
+	}
+
+	overridden := Rule{Command: "rule-cmd", ErrorExitCodes: []int{1}, timeout: time.Second}.apply(cfg)
+	if overridden.timeout != time.Second {
+		t.Errorf("expected rule timeout, got %s", overridden.timeout)
+	}
+	if overridden.successCodes[0] || !overridden.errorCodes[1] || !overridden.filterOnCodes {
+		t.Errorf("expected rule exit codes to replace global ones: %+v", overridden)
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	files := []string{"main.go", "vendor/x.go", "run.sh", "data.json"}
+	cfg := Config{
+		exclude:   []string{"vendor/**"},
+		rules:     []Rule{{Name: "fmt", Patterns: []string{"*.go"}, Command: "gofmt -l"}},
+		unmatched: unmatchedDefault,
+	}
+
+	result := selectFiles(files, cfg)
+	expected := []string{"main.go", "run.sh", "data.json"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	cfg.unmatched = unmatchedSkip
+	result = selectFiles(files, cfg)
+	expected = []string{"main.go"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestFilesToCheckUnmatchedSkip(t *testing.T) {
+	cfg := Config{
+		hashesFile: "hashes.jsonl",
+		rules:      []Rule{{Name: "fmt", Patterns: []string{"*.go"}, Command: "gofmt -l"}},
+		unmatched:  unmatchedSkip,
+	}
+	auditMap := map[string]AuditEntry{"main.go": {Filename: "main.go"}}
+
+	// Skipping every given file must not fall back to the hashes file
+	if files := filesToCheck([]string{"data.json"}, cfg, auditMap); len(files) != 0 {
+		t.Errorf("expected no files to check, got %v", files)
+	}
+	if files := filesToCheck(nil, cfg, auditMap); !reflect.DeepEqual(files, []string{"main.go"}) {
+		t.Errorf("expected the hashes file entries without files given, got %v", files)
+	}
+}
+
+func TestProcessFileWithRules(t *testing.T) {
+	tempDir := t.TempDir()
+	goFile := filepath.Join(tempDir, "main.go")
+	jsonFile := filepath.Join(tempDir, "data.json")
+	for _, f := range []string{goFile, jsonFile} {
+		if err := os.WriteFile(f, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{
+		command: "exit 7",
+		rules: []Rule{
+			{Name: "pass", Patterns: []string{"*.go"}, Command: "true"},
+			{Name: "fail", Patterns: []string{"*.go"}, Command: "exit 3"},
+			{Name: "late", Patterns: []string{"*.go"}, Command: "exit 4"},
+		},
+		ruleMode:  ruleModeFirst,
+		unmatched: unmatchedDefault,
+		quiet:     true,
+	}
+
+	t.Run("first matching rule", func(t *testing.T) {
+		result := processFile(goFile, cfg, nil)
+		if result == nil {
+			t.Fatal("expected result")
+		}
+		if result.ExitCode != 0 || result.Rule != "pass" {
+			t.Errorf("expected exit 0 from rule 'pass', got %d from %q", result.ExitCode, result.Rule)
+		}
+	})
+
+	t.Run("all matching rules", func(t *testing.T) {
+		allCfg := cfg
+		allCfg.ruleMode = ruleModeAll
+		result := processFile(goFile, allCfg, nil)
+		if result == nil {
+			t.Fatal("expected result")
+		}
+		if result.ExitCode != 3 {
+			t.Errorf("expected first failing exit code 3, got %d", result.ExitCode)
+		}
+		if result.Rule != "pass,fail,late" {
+			t.Errorf("expected all rule names, got %q", result.Rule)
+		}
+	})
+
+	t.Run("rule exit code filter", func(t *testing.T) {
+		filterCfg := cfg
+		filterCfg.rules = []Rule{{Name: "fail", Patterns: []string{"*.go"}, Command: "exit 3", SuccessExitCodes: []int{0}}}
+		if result := processFile(goFile, filterCfg, nil); result != nil {
+			t.Errorf("expected result to be filtered by the rule's exit codes, got %+v", result)
+		}
+	})
+
+	t.Run("unmatched default", func(t *testing.T) {
+		result := processFile(jsonFile, cfg, nil)
+		if result == nil {
+			t.Fatal("expected result")
+		}
+		if result.ExitCode != 7 || result.Rule != "" {
+			t.Errorf("expected default command exit 7 without rule, got %d from %q", result.ExitCode, result.Rule)
+		}
+	})
+
+	t.Run("unmatched skip", func(t *testing.T) {
+		skipCfg := cfg
+		skipCfg.unmatched = unmatchedSkip
+		if result := processFile(jsonFile, skipCfg, nil); result != nil {
+			t.Errorf("expected unmatched file to be skipped, got %+v", result)
+		}
+	})
+}
+
+func TestWriteResultsRecordsRule(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+
+	results := make(chan *Result, 1)
+	results <- &Result{Filename: "main.go", Hash: "hash1", Rule: "fmt"}
+	close(results)
+
+	done := make(chan bool)
+	cfg := Config{update: true, hashesFile: hashesFile, quiet: true}
+	go writeResults(results, io.Discard, done, cfg)
+	<-done
+
+	mergeHashFiles(hashesFile)
+	entries := loadAuditFile(hashesFile)
+	if entries["main.go"].Rule != "fmt" {
+		t.Errorf("expected rule 'fmt' to be stored, got %+v", entries["main.go"])
+	}
+}
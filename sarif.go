@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIF 2.1.0 objects, as far as code-scanning dashboards need them.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifReport collects the failed checks of a run into a SARIF log with a
+// result per problem found in their output, see diagnostics.
+type sarifReport struct {
+	cfg     Config
+	pattern *regexp.Regexp
+	rules   map[string]bool
+	results []sarifResult
+}
+
+func newSARIFReport(cfg Config) *sarifReport {
+	return &sarifReport{cfg: cfg, pattern: cfg.diagnosticPattern, rules: make(map[string]bool)}
+}
+
+// add records the problems of result if its check failed.
+func (r *sarifReport) add(result *Result) {
+	if !result.failed {
+		return
+	}
+
+	rule := checkName(r.cfg, result)
+	r.rules[rule] = true
+	for _, d := range diagnostics(result, r.pattern) {
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(d.file)}}
+		if d.line > 0 {
+			location.Region = &sarifRegion{StartLine: d.line, StartColumn: d.column}
+		}
+		r.results = append(r.results, sarifResult{
+			RuleID:    rule,
+			Level:     "error",
+			Message:   sarifMessage{Text: d.message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+}
+
+// write writes the SARIF log to the --sarif file, with results sorted by
+// location.
+func (r *sarifReport) write() error {
+	driver := sarifDriver{Name: "ghc", InformationURI: "https://github.com/rwese/GoHashCheckMe", Rules: []sarifRule{}}
+	for id := range r.rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: id}})
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool {
+		return driver.Rules[i].ID < driver.Rules[j].ID
+	})
+
+	results := r.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Locations[0].PhysicalLocation, results[j].Locations[0].PhysicalLocation
+		if a.ArtifactLocation.URI != b.ArtifactLocation.URI {
+			return a.ArtifactLocation.URI < b.ArtifactLocation.URI
+		}
+		return a.Region != nil && (b.Region == nil || a.Region.StartLine < b.Region.StartLine)
+	})
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.cfg.sarifFile, append(data, '\n'), 0644)
+}
+
+// sarifURI turns a path into an artifact URI: relative paths stay relative
+// to the working directory, the usual base of code-scanning uploads.
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		return "file://" + filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	defaultPattern := regexp.MustCompile("(?m)" + defaultDiagnosticPattern)
+	custom, err := parseDiagnosticPattern(`^L(?P<line>\d+) (?P<msg>.*)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern *regexp.Regexp
+		result  Result
+		want    []diagnostic
+	}{
+		{
+			name:    "file, line and column",
+			pattern: defaultPattern,
+			result:  Result{Filename: "a.go", ExitCode: 1, output: "a.go:3:7: unused variable\r\nsrc/b.go:10: missing return\n"},
+			want:    []diagnostic{{"a.go", 3, 7, "unused variable"}, {"src/b.go", 10, 0, "missing return"}},
+		},
+		{
+			name:    "no match",
+			pattern: defaultPattern,
+			result:  Result{Filename: "a.go", ExitCode: 2, Cached: true, output: "something broke\n"},
+			want:    []diagnostic{{"a.go", 0, 0, "exit code 2 (cached)"}},
+		},
+		{
+			name:    "custom pattern without file",
+			pattern: custom,
+			result:  Result{Filename: "a.txt", ExitCode: 1, output: "L4 trailing space\n"},
+			want:    []diagnostic{{"a.txt", 4, 0, "trailing space"}},
+		},
+		{
+			name:    "command not run",
+			pattern: nil,
+			result:  Result{Filename: "a.txt", ExitCode: -1},
+			want:    []diagnostic{{"a.txt", 0, 0, "command could not be run"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagnostics(&tt.result, tt.pattern)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d diagnostics, got %+v", len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("diagnostic %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseDiagnosticPattern(t *testing.T) {
+	for _, pattern := range []string{`(`, `^(?P<file>.*)$`} {
+		if _, err := parseDiagnosticPattern(pattern); err == nil {
+			t.Errorf("expected %q to be rejected", pattern)
+		}
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	tempDir := t.TempDir()
+	sarifFile := filepath.Join(tempDir, "results.sarif")
+	pass := filepath.Join(tempDir, "pass.txt")
+	fail := filepath.Join(tempDir, "fail.txt")
+	for _, file := range []string{pass, fail} {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{
+		command:           "case $FILE in *fail*) echo 'line 12: too long'; exit 1;; esac",
+		sarifFile:         sarifFile,
+		diagnosticPattern: regexp.MustCompile(`(?m)^line (?P<line>\d+): (?P<msg>.*)$`),
+		workers:           2,
+		quiet:             true,
+	}
+	processFiles([]string{pass, fail}, cfg, nil, io.Discard)
+
+	data, err := os.ReadFile(sarifFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF: %v\n%s", err, data)
+	}
+	if log.Version != sarifVersion || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Fatalf("expected one run with one rule, got %s", data)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("expected a result for the failed file only, got %s", data)
+	}
+	location := results[0].Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "file://"+filepath.ToSlash(fail) || location.Region == nil || location.Region.StartLine != 12 {
+		t.Errorf("expected location %s line 12, got %+v", fail, location)
+	}
+	if results[0].RuleID != cfg.command || results[0].Message.Text != "too long" {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slowestFiles is the number of slowest files listed in the statistics.
+const slowestFiles = 10
+
+// RunStats is the end-of-run summary record: the outcomes of a run and how
+// its time was spent. Times are in milliseconds; HashThroughput is in bytes
+// per second spent hashing, per worker.
+type RunStats struct {
+	Type           string           `json:"type"`
+	Total          int              `json:"total"`
+	New            int              `json:"new"`
+	Changed        int              `json:"changed"`
+	Unchanged      int              `json:"unchanged"`
+	Renamed        int              `json:"renamed,omitempty"`
+	Trusted        int              `json:"trusted,omitempty"`
+	Skipped        int              `json:"skipped"`
+	Passed         int              `json:"passed"`
+	Failed         int              `json:"failed"`
+	Errored        int              `json:"errored"`
+	BytesHashed    int64            `json:"bytes_hashed"`
+	HashThroughput float64          `json:"hash_throughput"`
+	DurationMS     float64          `json:"duration_ms"`
+	CommandTime    CommandTimeStats `json:"command_time"`
+	Slowest        []FileTimeStats  `json:"slowest"`
+	CacheHitRatio  float64          `json:"cache_hit_ratio"`
+}
+
+// CommandTimeStats sums up the time the checks of each file took.
+type CommandTimeStats struct {
+	TotalMS float64 `json:"total_ms"`
+	P50MS   float64 `json:"p50_ms"`
+	P95MS   float64 `json:"p95_ms"`
+	MaxMS   float64 `json:"max_ms"`
+}
+
+// FileTimeStats is the time the checks of a file took.
+type FileTimeStats struct {
+	Filename   string  `json:"filename"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// stats returns the statistics of the run s summarizes, once it is done.
+func (s *Summary) stats() *RunStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := &RunStats{
+		Type:        "summary",
+		Total:       s.Processed,
+		New:         s.New,
+		Changed:     s.Changed,
+		Unchanged:   s.Unchanged,
+		Renamed:     s.Renamed,
+		Trusted:     s.Trusted,
+		Skipped:     s.Skipped,
+		Passed:      s.Passed,
+		Failed:      s.Failed,
+		Errored:     s.Errors,
+		BytesHashed: s.bytesHashed,
+		DurationMS:  milliseconds(time.Since(s.started)),
+		Slowest:     []FileTimeStats{},
+	}
+	if s.hashTime > 0 {
+		stats.HashThroughput = float64(s.bytesHashed) / s.hashTime.Seconds()
+	}
+	if checked := len(s.checkTimes) + s.Skipped; checked > 0 {
+		stats.CacheHitRatio = float64(s.Skipped) / float64(checked)
+	}
+
+	times := make([]fileTime, len(s.checkTimes))
+	copy(times, s.checkTimes)
+	sort.SliceStable(times, func(i, j int) bool {
+		return times[i].duration > times[j].duration
+	})
+	var total time.Duration
+	for _, t := range times {
+		total += t.duration
+	}
+	stats.CommandTime.TotalMS = milliseconds(total)
+	if len(times) > 0 {
+		stats.CommandTime.P50MS = milliseconds(percentile(times, 50))
+		stats.CommandTime.P95MS = milliseconds(percentile(times, 95))
+		stats.CommandTime.MaxMS = milliseconds(times[0].duration)
+	}
+	for _, t := range times[:min(len(times), slowestFiles)] {
+		stats.Slowest = append(stats.Slowest, FileTimeStats{t.filename, milliseconds(t.duration)})
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of times, sorted slowest first,
+// by the nearest-rank method.
+func percentile(times []fileTime, p int) time.Duration {
+	rank := (p*len(times) + 99) / 100
+	return times[len(times)-max(rank, 1)].duration
+}
+
+// milliseconds returns d in milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// writeStats writes the statistics of a run: to the --stats file if given,
+// and otherwise as the final record of the output in the --format. The
+// template and gitlab-codequality formats leave them out, since their output
+// has a shape set by the template or by GitLab; --stats works with any
+// format.
+func writeStats(cfg Config, output io.Writer, stats *RunStats) error {
+	if cfg.statsFile != "" {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(cfg.statsFile, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	switch cfg.format {
+	case formatJSONL:
+		if cfg.statsFile == "" {
+			return json.NewEncoder(output).Encode(stats)
+		}
+	case formatText, formatTable:
+		_, err := io.WriteString(output, "\n"+formatStats(stats))
+		return err
+	case formatGitHub:
+		if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+			return appendFile(path, "```\n"+formatStats(stats)+"```\n\n")
+		}
+	}
+	return nil
+}
+
+// formatStats renders stats for people.
+func formatStats(stats *RunStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %d files (%d new, %d changed, %d unchanged), %d skipped, %d passed, %d failed, %d errored\n",
+		stats.Total, stats.New, stats.Changed, stats.Unchanged, stats.Skipped, stats.Passed, stats.Failed, stats.Errored)
+	fmt.Fprintf(&b, "Hashed %s at %s/s, cache hit ratio %.0f%%, took %s\n",
+		formatBytes(stats.BytesHashed), formatBytes(int64(stats.HashThroughput)), stats.CacheHitRatio*100, formatMS(stats.DurationMS))
+	fmt.Fprintf(&b, "Command time: total %s, p50 %s, p95 %s, max %s\n",
+		formatMS(stats.CommandTime.TotalMS), formatMS(stats.CommandTime.P50MS), formatMS(stats.CommandTime.P95MS), formatMS(stats.CommandTime.MaxMS))
+	if len(stats.Slowest) > 0 {
+		b.WriteString("Slowest files:\n")
+		for _, t := range stats.Slowest {
+			fmt.Fprintf(&b, "  %8s  %s\n", formatMS(t.DurationMS), t.Filename)
+		}
+	}
+	return b.String()
+}
+
+// formatMS renders a time in milliseconds in seconds.
+func formatMS(ms float64) string {
+	return fmt.Sprintf("%.2fs", ms/1000)
+}
+
+// formatBytes renders a size in bytes in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryStats(t *testing.T) {
+	summary := &Summary{started: time.Now(), Processed: 25, Skipped: 5, bytesHashed: 2048, hashTime: time.Second}
+	for i := 1; i <= 20; i++ {
+		summary.addCheckTime(filepath.Join("src", string(rune('a'+i))), time.Duration(i)*time.Millisecond)
+	}
+
+	stats := summary.stats()
+	if stats.Type != "summary" || stats.Total != 25 || stats.Skipped != 5 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.HashThroughput != 2048 || stats.CacheHitRatio != 0.2 {
+		t.Errorf("expected 2048 B/s and a 0.2 cache hit ratio, got %v and %v", stats.HashThroughput, stats.CacheHitRatio)
+	}
+	want := CommandTimeStats{TotalMS: 210, P50MS: 10, P95MS: 19, MaxMS: 20}
+	if stats.CommandTime != want {
+		t.Errorf("command time = %+v, want %+v", stats.CommandTime, want)
+	}
+	if len(stats.Slowest) != slowestFiles || stats.Slowest[0].DurationMS != 20 || stats.Slowest[slowestFiles-1].DurationMS != 11 {
+		t.Errorf("expected the %d slowest files, slowest first, got %+v", slowestFiles, stats.Slowest)
+	}
+
+	empty := (&Summary{started: time.Now()}).stats()
+	if empty.CommandTime != (CommandTimeStats{}) || empty.CacheHitRatio != 0 || empty.Slowest == nil {
+		t.Errorf("unexpected stats of an empty run: %+v", empty)
+	}
+}
+
+func TestProcessFilesStats(t *testing.T) {
+	tempDir := t.TempDir()
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		file := filepath.Join(tempDir, name)
+		if err := os.WriteFile(file, []byte("12345"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	cfg := Config{command: "true", audit: true, update: true, hashesFile: hashesFile, workers: 2, quiet: true}
+	stats := processFiles(files, cfg, loadAuditFile(hashesFile), io.Discard).stats()
+	if stats.New != 2 || stats.Passed != 2 || stats.BytesHashed != 10 || len(stats.Slowest) != 2 {
+		t.Errorf("unexpected stats of the first run: %+v", stats)
+	}
+	mergeHashFiles(hashesFile)
+
+	stats = processFiles(files, cfg, loadAuditFile(hashesFile), io.Discard).stats()
+	if stats.Unchanged != 2 || stats.Skipped != 2 || stats.CacheHitRatio != 1 || len(stats.Slowest) != 0 {
+		t.Errorf("unexpected stats of the second run: %+v", stats)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	stats := (&Summary{started: time.Now(), Processed: 3, Failed: 1}).stats()
+
+	var buf bytes.Buffer
+	if err := writeStats(Config{format: formatJSONL}, &buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil || record["type"] != "summary" || record["failed"] != 1.0 {
+		t.Errorf("expected a summary record, got %s (%v)", buf.String(), err)
+	}
+
+	// With --stats the record goes to the file instead
+	buf.Reset()
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+	if err := writeStats(Config{format: formatJSONL, statsFile: statsFile}, &buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no summary record in the output, got %s", buf.String())
+	}
+	var loaded RunStats
+	if data, err := os.ReadFile(statsFile); err != nil || json.Unmarshal(data, &loaded) != nil || loaded.Total != 3 {
+		t.Errorf("expected stats file, got %+v (%v)", loaded, err)
+	}
+
+	buf.Reset()
+	if err := writeStats(Config{format: formatText}, &buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Summary: 3 files") {
+		t.Errorf("expected a summary for people, got %s", buf.String())
+	}
+
+	// Formats whose output has a shape of its own only get stats with --stats
+	for _, format := range []string{formatTemplate, formatGitLabCodeQuality} {
+		buf.Reset()
+		if err := writeStats(Config{format: format}, &buf, stats); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no stats in the %s output, got %s", format, buf.String())
+		}
+		if err := os.Remove(statsFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeStats(Config{format: format, statsFile: statsFile}, &buf, stats); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(statsFile); err != nil || buf.Len() != 0 {
+			t.Errorf("expected the %s stats in the stats file only, got %v and %s", format, err, buf.String())
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 40:         "3.0 TiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
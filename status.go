@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// File classifications reported by the status command.
+const (
+	statusNew       = "new"
+	statusChanged   = "changed"
+	statusUnchanged = "unchanged"
+	statusMissing   = "missing"
+	statusRenamed   = "renamed"
+	statusTrusted   = "trusted"
+)
+
+// classify returns the status of a hashed file from its audit information.
+func classify(result *Result) string {
+	switch {
+	case result.RenamedFrom != "":
+		return statusRenamed
+	case result.Audited && !result.Changed:
+		return statusUnchanged
+	case result.TrustedFrom != "":
+		return statusTrusted
+	case !result.Audited:
+		return statusNew
+	default:
+		return statusChanged
+	}
+}
+
+// needsCheck reports whether a status run found files that a run would check.
+func (s *Summary) needsCheck() bool {
+	return s.New > 0 || s.Changed > 0
+}
+
+// statusExitCode returns the exit code of a status run: 3 if files could not
+// be hashed, 1 if files need checking and 0 otherwise.
+func (s *Summary) statusExitCode() int {
+	switch {
+	case s.Errors > 0:
+		return exitError
+	case s.needsCheck():
+		return exitCheckFailed
+	}
+	return exitOK
+}
+
+// statusRecord is the JSONL record of a status result. It has no exit code,
+// as no check ran.
+type statusRecord struct {
+	*Result
+	ExitCode *int `json:"exit_code,omitempty"`
+}
+
+// jsonRecord returns the record written for result in JSONL output.
+func jsonRecord(result *Result) any {
+	if result.Status != "" {
+		return statusRecord{Result: result}
+	}
+	return result
+}
+
+// printStatusSummary writes the summary block of a status run.
+func printStatusSummary(w io.Writer, s *Summary) {
+	fmt.Fprintf(w, "Status summary:\n")
+	fmt.Fprintf(w, "  New:       %d\n", s.New)
+	fmt.Fprintf(w, "  Changed:   %d\n", s.Changed)
+	fmt.Fprintf(w, "  Unchanged: %d\n", s.Unchanged)
+	fmt.Fprintf(w, "  Missing:   %d\n", s.Missing)
+	if s.Renamed > 0 {
+		fmt.Fprintf(w, "  Renamed:   %d\n", s.Renamed)
+	}
+	if s.Trusted > 0 {
+		fmt.Fprintf(w, "  Trusted:   %d\n", s.Trusted)
+	}
+	if s.Errors > 0 {
+		fmt.Fprintf(w, "  Errors:    %d\n", s.Errors)
+	}
+}
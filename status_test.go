@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   Result
+		expected string
+	}{
+		{"not in hashes file", Result{}, statusNew},
+		{"changed", Result{Audited: true, Changed: true}, statusChanged},
+		{"unchanged", Result{Audited: true}, statusUnchanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(&tt.result); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestProcessFileDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	unchanged := filepath.Join(tempDir, "unchanged.txt")
+	changed := filepath.Join(tempDir, "changed.txt")
+	newFile := filepath.Join(tempDir, "new.txt")
+	missing := filepath.Join(tempDir, "missing.txt")
+	for _, f := range []string{unchanged, changed, newFile} {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unchangedHash, err := hashFile(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditMap := map[string]AuditEntry{
+		unchanged: {Filename: unchanged, Hash: unchangedHash},
+		changed:   {Filename: changed, Hash: "old"},
+		missing:   {Filename: missing, Hash: "gone"},
+	}
+
+	// The command would fail, so a zero exit code shows it never ran
+	cfg := Config{command: "false", dryRun: true, quiet: true}
+
+	expected := map[string]string{
+		unchanged: statusUnchanged,
+		changed:   statusChanged,
+		newFile:   statusNew,
+		missing:   statusMissing,
+	}
+	for filename, status := range expected {
+		result := processFile(filename, cfg, auditMap)
+		if result == nil {
+			t.Fatalf("expected result for %s", filename)
+		}
+		if result.Status != status {
+			t.Errorf("expected %s to be %s, got %s", filename, status, result.Status)
+		}
+		if result.ExitCode != 0 {
+			t.Errorf("expected command not to run for %s, got exit code %d", filename, result.ExitCode)
+		}
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	summary := &Summary{Unchanged: 3, Missing: 1}
+	if summary.needsCheck() {
+		t.Error("unchanged and missing files should not need checking")
+	}
+
+	if code := summary.statusExitCode(); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	summary.Changed = 1
+	if !summary.needsCheck() {
+		t.Error("changed files should need checking")
+	}
+	if code := summary.statusExitCode(); code != exitCheckFailed {
+		t.Errorf("expected exit code %d when files need checking, got %d", exitCheckFailed, code)
+	}
+
+	summary.addError()
+	if code := summary.statusExitCode(); code != exitError {
+		t.Errorf("expected exit code %d when files can't be hashed, got %d", exitError, code)
+	}
+
+	var buf bytes.Buffer
+	printStatusSummary(&buf, &Summary{New: 2, Changed: 1, Unchanged: 3, Missing: 4})
+	for _, line := range []string{"New:       2", "Changed:   1", "Unchanged: 3", "Missing:   4"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected %q in summary, got:\n%s", line, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Errors") {
+		t.Error("expected no errors line without errors")
+	}
+}
+
+func TestProcessFilesDryRunSummary(t *testing.T) {
+	tempDir := t.TempDir()
+	known := filepath.Join(tempDir, "known.txt")
+	unknown := filepath.Join(tempDir, "unknown.txt")
+	for _, f := range []string{known, unknown} {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditMap := map[string]AuditEntry{known: {Filename: known, Hash: hash}}
+
+	var buf bytes.Buffer
+	cfg := Config{command: "false", dryRun: true, workers: 2, quiet: true}
+	summary := processFiles([]string{known, unknown}, cfg, auditMap, &buf)
+
+	if summary.New != 1 || summary.Unchanged != 1 {
+		t.Errorf("expected 1 new and 1 unchanged, got %+v", summary)
+	}
+	if !summary.needsCheck() {
+		t.Error("expected new file to need checking")
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("expected 2 status records, got %d", lines)
+	}
+	// No check ran, so the records don't claim an exit code
+	if strings.Contains(buf.String(), "exit_code") {
+		t.Errorf("expected no exit code in status records, got:\n%s", buf.String())
+	}
+
+	// A file that can't be hashed makes the status run fail
+	summary = processFiles([]string{known, filepath.Join(tempDir, "absent.txt")}, cfg, auditMap, &buf)
+	if summary.Errors != 1 || summary.statusExitCode() != exitError {
+		t.Errorf("expected 1 error, got %+v", summary)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Summary counts the outcomes of the files processed in a run. It is safe
+// for concurrent use by the workers. Passed and Failed count checks by their
+// exit code before any exit-code filtering; Errors counts files that could
+// not be hashed and commands that could not be run. Skipped counts files
+// whose checks didn't run because their result was replayed or trusted.
+type Summary struct {
+	mu        sync.Mutex
+	Processed int
+	Passed    int
+	Failed    int
+	Errors    int
+	Skipped   int
+	New       int
+	Changed   int
+	Unchanged int
+	Missing   int
+	Renamed   int
+	Trusted   int
+
+	started     time.Time
+	bytesHashed int64
+	hashTime    time.Duration
+	checkTimes  []fileTime
+}
+
+// fileTime is the time the checks of a file took.
+type fileTime struct {
+	filename string
+	duration time.Duration
+}
+
+// add records the result of one file; a nil result is a file that was
+// left out of the output.
+func (s *Summary) add(result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Processed++
+	if result != nil {
+		s.countStatus(result.Status)
+	}
+}
+
+// addStatus records the classification of a file in a run, which unlike
+// in a status run isn't part of its result. A nil summary records nothing.
+func (s *Summary) addStatus(status string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.countStatus(status)
+}
+
+// countStatus counts a file of the given status; s.mu must be held.
+func (s *Summary) countStatus(status string) {
+	switch status {
+	case statusNew:
+		s.New++
+	case statusChanged:
+		s.Changed++
+	case statusUnchanged:
+		s.Unchanged++
+	case statusMissing:
+		s.Missing++
+	case statusRenamed:
+		s.Renamed++
+	case statusTrusted:
+		s.Trusted++
+	}
+}
+
+// addCheck records the exit code of a check run or replayed for a file, as
+// judged by the configuration of the check that decided it. A nil summary
+// records nothing.
+func (s *Summary) addCheck(cfg Config, exitCode int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case exitCode == -1:
+		s.Errors++
+	case succeeded(cfg, exitCode):
+		s.Passed++
+	default:
+		s.Failed++
+	}
+}
+
+// addHashed records a file of size bytes hashed in d.
+func (s *Summary) addHashed(size int64, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.bytesHashed += size
+	s.hashTime += d
+	s.mu.Unlock()
+}
+
+// addCheckTime records the time the checks of filename took.
+func (s *Summary) addCheckTime(filename string, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.checkTimes = append(s.checkTimes, fileTime{filename, d})
+	s.mu.Unlock()
+}
+
+// addSkipped records a file whose checks didn't run.
+func (s *Summary) addSkipped() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Skipped++
+	s.mu.Unlock()
+}
+
+// addError records a file that could not be processed. A nil summary
+// records nothing.
+func (s *Summary) addError() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Errors++
+	s.mu.Unlock()
+}
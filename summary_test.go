@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSummaryAdd(t *testing.T) {
+	summary := &Summary{}
+	summary.add(&Result{Status: statusNew})
+	summary.add(&Result{Status: statusChanged})
+	summary.add(&Result{Status: statusUnchanged})
+	summary.add(&Result{Status: statusUnchanged})
+	summary.add(&Result{Status: statusMissing})
+	summary.add(&Result{})
+	summary.add(nil)
+	summary.addError()
+
+	if summary.Processed != 7 {
+		t.Errorf("expected 7 processed, got %d", summary.Processed)
+	}
+	if summary.New != 1 || summary.Changed != 1 || summary.Unchanged != 2 || summary.Missing != 1 {
+		t.Errorf("unexpected status counts: %+v", summary)
+	}
+	if summary.Errors != 1 {
+		t.Errorf("expected only the file that could not be processed to count as an error, got %d", summary.Errors)
+	}
+
+	var none *Summary
+	none.addError()
+}
+
+func TestSummaryAddCheck(t *testing.T) {
+	summary := &Summary{}
+	summary.addCheck(Config{}, 0)
+	summary.addCheck(Config{}, 1)
+	summary.addCheck(Config{successCodes: map[int]bool{0: true, 1: true}}, 1)
+	summary.addCheck(Config{successCodes: map[int]bool{1: true}}, 0)
+	summary.addCheck(Config{}, -1)
+	summary.addError()
+
+	if summary.Passed != 2 || summary.Failed != 2 || summary.Errors != 2 {
+		t.Errorf("expected 2 passed, 2 failed and 2 errors, got %+v", summary)
+	}
+
+	var none *Summary
+	none.addCheck(Config{}, 1)
+	none.addError()
+}
+
+func TestSummaryExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		passed int
+		failed int
+		errors int
+		policy string
+		want   int
+	}{
+		{"all passed", 3, 0, 0, failOnFailure, exitOK},
+		{"nothing ran", 0, 0, 0, failOnFailure, exitOK},
+		{"failed", 2, 1, 0, failOnFailure, exitCheckFailed},
+		{"error", 2, 0, 1, failOnFailure, exitError},
+		{"error outranks failure", 0, 1, 1, failOnFailure, exitError},
+		{"failure ignored", 0, 1, 0, failOnError, exitOK},
+		{"error with error policy", 0, 1, 1, failOnError, exitError},
+		{"none", 0, 1, 1, failOnNone, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := &Summary{Passed: tt.passed, Failed: tt.failed, Errors: tt.errors}
+			if got := summary.exitCode(tt.policy); got != tt.want {
+				t.Errorf("exitCode(%q) = %d, want %d", tt.policy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryConcurrent(t *testing.T) {
+	summary := &Summary{}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				summary.add(&Result{Status: statusNew})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if summary.Processed != 1000 || summary.New != 1000 {
+		t.Errorf("expected 1000 processed and new, got %d and %d", summary.Processed, summary.New)
+	}
+}
+
+func TestProcessFilesCountsOutcomes(t *testing.T) {
+	tempDir := t.TempDir()
+	var files []string
+	for name, content := range map[string]string{"pass.txt": "ok", "fail.txt": "bad"} {
+		file := filepath.Join(tempDir, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	files = append(files, filepath.Join(tempDir, "missing.txt"))
+
+	// The failure is filtered from the output but still counts
+	cfg := Config{
+		command:       `test "$(cat $FILE)" = ok`,
+		successCodes:  map[int]bool{0: true},
+		filterOnCodes: true,
+		workers:       2,
+		quiet:         true,
+	}
+	summary := processFiles(files, cfg, nil, io.Discard)
+	if summary.Passed != 1 || summary.Failed != 1 || summary.Errors != 1 {
+		t.Errorf("expected 1 passed, 1 failed and 1 error, got %+v", summary)
+	}
+	if code := summary.exitCode(failOnFailure); code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"text/template"
+	"time"
+)
+
+// templateResult is what a --template is rendered with: the fields of the
+// result, plus the details that aren't part of the JSONL output.
+type templateResult struct {
+	*Result
+	Failed   bool
+	Duration time.Duration
+	Output   string
+}
+
+// parseTemplate parses a --template.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("result").Parse(text)
+}
+
+// templateEncoder renders the --template for each result, on a line of its
+// own unless it ends with a newline. A result the template renders nothing
+// for is left out.
+type templateEncoder struct {
+	output io.Writer
+	tmpl   *template.Template
+}
+
+func (e *templateEncoder) encode(result *Result) error {
+	var buf bytes.Buffer
+	data := templateResult{Result: result, Failed: result.failed, Duration: result.duration, Output: result.output}
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err := e.output.Write(buf.Bytes())
+	return err
+}
+
+func (e *templateEncoder) close() error {
+	return nil
+}
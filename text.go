@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of a run for people.
+const (
+	formatTable    = "table"
+	formatTemplate = "template"
+)
+
+// ANSI colors of the outcomes.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// Outcomes of a file in text output.
+const (
+	outcomePass  = "PASS"
+	outcomeFail  = "FAIL"
+	outcomeSkip  = "SKIP"
+	outcomeError = "ERROR"
+)
+
+// useColor reports whether output is a terminal that should get colors:
+// not when NO_COLOR is set, see https://no-color.org.
+func useColor(output io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := output.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// outcome returns the outcome of result, or its status in a status run, and
+// details about it.
+func outcome(result *Result) (string, string) {
+	switch {
+	case result.Status != "":
+		return strings.ToUpper(result.Status), ""
+	case result.ExitCode == -1:
+		return outcomeError, "command could not be run"
+	case result.failed:
+		details := fmt.Sprintf("exit=%d", result.ExitCode)
+		if result.Cached {
+			details += " (cached)"
+		}
+		return outcomeFail, details
+	case result.Cached:
+		return outcomeSkip, "(unchanged)"
+	case result.TrustedFrom != "":
+		return outcomeSkip, "(trusted: " + result.TrustedFrom + ")"
+	}
+	return outcomePass, fmt.Sprintf("(%.1fs)", result.duration.Seconds())
+}
+
+// colorize wraps label in the color of its outcome.
+func colorize(label string) string {
+	color := colorYellow
+	switch label {
+	case outcomePass:
+		color = colorGreen
+	case outcomeFail, outcomeError:
+		color = colorRed
+	}
+	return color + label + colorReset
+}
+
+// textEncoder prints a line per file, such as "PASS  src/a.go (0.3s)".
+type textEncoder struct {
+	output io.Writer
+	color  bool
+}
+
+func newTextEncoder(output io.Writer) *textEncoder {
+	return &textEncoder{output: output, color: useColor(output)}
+}
+
+func (e *textEncoder) encode(result *Result) error {
+	label, details := outcome(result)
+	padded := fmt.Sprintf("%-5s", label)
+	if e.color {
+		padded = colorize(label) + padded[len(label):]
+	}
+	line := padded + " " + result.Filename
+	if details != "" {
+		line += " " + details
+	}
+	_, err := fmt.Fprintln(e.output, line)
+	return err
+}
+
+func (e *textEncoder) close() error {
+	return nil
+}
+
+// tableEncoder prints a table of all files, sorted by name, with a line of
+// totals once the run is done.
+type tableEncoder struct {
+	output  io.Writer
+	color   bool
+	results []*Result
+}
+
+func newTableEncoder(output io.Writer) *tableEncoder {
+	return &tableEncoder{output: output, color: useColor(output)}
+}
+
+func (e *tableEncoder) encode(result *Result) error {
+	e.results = append(e.results, result)
+	return nil
+}
+
+func (e *tableEncoder) close() error {
+	sort.Slice(e.results, func(i, j int) bool {
+		return e.results[i].Filename < e.results[j].Filename
+	})
+
+	// Colors are added once the columns are laid out, so they don't count
+	// towards their width
+	var table strings.Builder
+	counts := make(map[string]int)
+	labels := make([]string, len(e.results))
+	showRules := slices.ContainsFunc(e.results, func(result *Result) bool { return result.Rule != "" })
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "STATUS\tFILE\tEXIT\tTIME")
+	if showRules {
+		fmt.Fprint(w, "\tRULE")
+	}
+	fmt.Fprintln(w)
+	for i, result := range e.results {
+		label, _ := outcome(result)
+		counts[label]++
+		labels[i] = label
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1fs", label, result.Filename, result.ExitCode, result.duration.Seconds())
+		if showRules {
+			fmt.Fprintf(w, "\t%s", result.Rule)
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		line = strings.TrimRight(line, " ")
+		if e.color && i > 0 {
+			line = colorize(labels[i-1]) + strings.TrimPrefix(line, labels[i-1])
+		}
+		out.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&out, "\n%d files: %d passed, %d failed, %d skipped, %d errors\n",
+		len(e.results), counts[outcomePass], counts[outcomeFail], counts[outcomeSkip], counts[outcomeError])
+
+	_, err := io.WriteString(e.output, out.String())
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeAll(t *testing.T, encoder resultEncoder, results []*Result) {
+	t.Helper()
+	for _, result := range results {
+		if err := encoder.encode(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var textResults = []*Result{
+	{Filename: "src/b.go", ExitCode: 1, failed: true, Rule: "vet"},
+	{Filename: "src/a.go", duration: 300 * time.Millisecond},
+	{Filename: "src/c.go", Audited: true, Cached: true},
+	{Filename: "src/d.go", ExitCode: 2, failed: true, Cached: true},
+	{Filename: "src/e.go", ExitCode: -1, failed: true},
+	{Filename: "src/f.go", TrustedFrom: "lib/f.go"},
+}
+
+func TestTextEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	encodeAll(t, newResultEncoder(Config{format: formatText}, &buf), textResults)
+
+	want := `FAIL  src/b.go exit=1
+PASS  src/a.go (0.3s)
+SKIP  src/c.go (unchanged)
+FAIL  src/d.go exit=2 (cached)
+ERROR src/e.go command could not be run
+SKIP  src/f.go (trusted: lib/f.go)
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTableEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	encodeAll(t, newResultEncoder(Config{format: formatTable}, &buf), textResults)
+
+	want := `STATUS  FILE      EXIT  TIME  RULE
+PASS    src/a.go  0     0.3s
+FAIL    src/b.go  1     0.0s  vet
+SKIP    src/c.go  0     0.0s
+FAIL    src/d.go  2     0.0s
+ERROR   src/e.go  -1    0.0s
+SKIP    src/f.go  0     0.0s
+
+6 files: 1 passed, 2 failed, 2 skipped, 1 errors
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	var buf bytes.Buffer
+	if useColor(&buf) {
+		t.Error("expected no color for a buffer")
+	}
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Setenv("NO_COLOR", "1")
+	if useColor(f) {
+		t.Error("expected NO_COLOR to disable color")
+	}
+
+	encoder := &textEncoder{output: &buf, color: true}
+	encodeAll(t, encoder, textResults[:1])
+	if !strings.HasPrefix(buf.String(), colorRed+"FAIL"+colorReset+"  src/b.go") {
+		t.Errorf("expected colored label, got %q", buf.String())
+	}
+}
+
+func TestTemplateEncoder(t *testing.T) {
+	tmpl, err := parseTemplate(`{{if .Failed}}{{.Filename}} {{.ExitCode}} {{.Rule}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	encodeAll(t, newResultEncoder(Config{format: formatTemplate, template: tmpl}, &buf), textResults[:3])
+
+	if buf.String() != "src/b.go 1 vet\n" {
+		t.Errorf("expected a line for the failed file only, got %q", buf.String())
+	}
+
+	if _, err := parseTemplate("{{.Filename"); err == nil {
+		t.Error("expected invalid template to be rejected")
+	}
+}
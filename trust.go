@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// trustIndex maps (hash, check) pairs that passed to the file they passed
+// for, so with --trust-content identical content is only checked once under
+// any path. It is safe for concurrent use by the workers.
+type trustIndex struct {
+	mu     sync.Mutex
+	passed map[string]string
+}
+
+// newTrustIndex indexes the hashes file entries that passed a recorded check
+// and haven't expired.
+func newTrustIndex(cfg Config, auditMap map[string]AuditEntry) *trustIndex {
+	ti := &trustIndex{passed: make(map[string]string)}
+	for name, entry := range auditMap {
+		if entry.Check != "" && entry.ExitCode == 0 && !expired(cfg, entry) {
+			ti.passed[trustKey(entry.Hash, entry.Check)] = name
+		}
+	}
+	return ti
+}
+
+// lookup returns the file that content with hash passed check for.
+func (ti *trustIndex) lookup(hash, check string) (string, bool) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	name, ok := ti.passed[trustKey(hash, check)]
+	return name, ok
+}
+
+// add records that filename with hash passed check, so later files in the
+// same run can be trusted too.
+func (ti *trustIndex) add(hash, check, filename string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	key := trustKey(hash, check)
+	if _, exists := ti.passed[key]; !exists {
+		ti.passed[key] = filename
+	}
+}
+
+func trustKey(hash, check string) string {
+	return hash + "\x00" + check
+}
+
+// checkID identifies the commands the rules run on a file, so trust only
+// carries over between files that are checked the same way.
+func checkID(rules []Rule, cfg Config) string {
+	h := sha256.New()
+	for _, rule := range rules {
+		fmt.Fprintf(h, "%s\x00", rule.apply(cfg).command)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
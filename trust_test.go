@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckID(t *testing.T) {
+	cfg := Config{command: "lint"}
+	lint := []Rule{{Command: "lint"}}
+
+	if checkID(lint, cfg) != checkID([]Rule{{Name: "other name", Command: "lint"}}, cfg) {
+		t.Error("expected the same command to give the same check")
+	}
+	if checkID(lint, cfg) == checkID([]Rule{{Command: "lint --strict"}}, cfg) {
+		t.Error("expected a different command to give a different check")
+	}
+	if checkID(lint, cfg) == checkID([]Rule{{Command: "lint"}, {Command: "vet"}}, cfg) {
+		t.Error("expected an additional rule to give a different check")
+	}
+}
+
+func TestTrustIndex(t *testing.T) {
+	ti := newTrustIndex(Config{}, map[string]AuditEntry{
+		"a.txt": {Filename: "a.txt", Hash: "hash1", Check: "check1"},
+		"b.txt": {Filename: "b.txt", Hash: "hash2"},
+	})
+
+	if from, ok := ti.lookup("hash1", "check1"); !ok || from != "a.txt" {
+		t.Errorf("expected hash1 to be trusted from a.txt, got %q", from)
+	}
+	if _, ok := ti.lookup("hash1", "check2"); ok {
+		t.Error("expected trust not to carry over to another check")
+	}
+	if _, ok := ti.lookup("hash2", ""); ok {
+		t.Error("expected entries without a check not to be trusted")
+	}
+
+	ti.add("hash3", "check1", "c.txt")
+	ti.add("hash3", "check1", "d.txt")
+	if from, ok := ti.lookup("hash3", "check1"); !ok || from != "c.txt" {
+		t.Errorf("expected hash3 to be trusted from the first file, got %q", from)
+	}
+
+	if _, ok := newTrustIndex(Config{}, nil).lookup("hash1", "check1"); ok {
+		t.Error("expected empty index without hashes file")
+	}
+}
+
+func TestProcessFileTrustContent(t *testing.T) {
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "lib", "util.js")
+	vendored := filepath.Join(tempDir, "vendor", "util.js")
+	for _, f := range []string{original, vendored} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The command would fail, so a zero exit code shows it never ran
+	cfg := Config{command: "false", quiet: true}
+	check := checkID(rulesFor(cfg, vendored), cfg)
+	auditMap := map[string]AuditEntry{original: {Filename: original, Hash: hash, Check: check}}
+
+	result := processFile(vendored, cfg, auditMap)
+	if result == nil || result.ExitCode == 0 {
+		t.Fatalf("expected command to run without --trust-content, got %+v", result)
+	}
+
+	cfg.trusted = newTrustIndex(cfg, auditMap)
+	result = processFile(vendored, cfg, auditMap)
+	if result == nil || result.ExitCode != 0 || result.TrustedFrom != original {
+		t.Errorf("expected trusted result from %s, got %+v", original, result)
+	}
+
+	cfg.dryRun = true
+	if result := processFile(vendored, cfg, auditMap); result.Status != statusTrusted {
+		t.Errorf("expected status %s, got %s", statusTrusted, result.Status)
+	}
+
+	cfg.dryRun = false
+	cfg.command = "true"
+	cfg.trusted = newTrustIndex(cfg, auditMap)
+	if result := processFile(vendored, cfg, auditMap); result.TrustedFrom != "" {
+		t.Errorf("expected a changed command not to be trusted, got %+v", result)
+	}
+}
+
+func TestProcessFilesTrustWithinRun(t *testing.T) {
+	tempDir := t.TempDir()
+	logFile := filepath.Join(tempDir, "checked.log")
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		file := filepath.Join(tempDir, name)
+		if err := os.WriteFile(file, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	// One worker makes the order deterministic: the first file is checked,
+	// the identical others are trusted
+	cfg := Config{command: "echo >> " + logFile, trustContent: true, workers: 1, quiet: true}
+	var buf bytes.Buffer
+	processFiles(files, cfg, nil, &buf)
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 1 {
+		t.Errorf("expected the command to run once, ran %d times", lines)
+	}
+	if trusted := strings.Count(buf.String(), `"trusted_from"`); trusted != 2 {
+		t.Errorf("expected 2 trusted results, got %d:\n%s", trusted, buf.String())
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Outcomes of verifying a hashes file entry, as printed by sha256sum -c.
+const (
+	verifyOK      = "OK"
+	verifyFailed  = "FAILED"
+	verifyMissing = "MISSING"
+)
+
+// Output formats of the verify command.
+const (
+	formatText  = "text"
+	formatJSONL = "jsonl"
+)
+
+type verifyResult struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runVerify checks every entry of the hashes file against the file on disk
+// and reports OK, FAILED or MISSING for each one, either as sha256sum -c
+// style lines or as JSONL. It returns the process exit code: 0 if every file
+// matched, 1 otherwise. Like GNU sha256sum, --ignore-missing neither reports
+// nor fails on missing files, and --strict fails on improperly formatted
+// entries, which are otherwise only warned about.
+func runVerify(cfg Config, output io.Writer) int {
+	auditMap := loadAuditFile(cfg.hashesFile)
+
+	// Entries without a filename or a valid SHA256 hash can't be verified
+	malformed := 0
+	for name, entry := range auditMap {
+		if !validEntry(entry) {
+			malformed++
+			delete(auditMap, name)
+		}
+	}
+
+	results := verifyEntries(auditMap, cfg.workers)
+
+	var encoder *json.Encoder
+	if cfg.format == formatJSONL {
+		encoder = json.NewEncoder(output)
+	}
+
+	verified, failed, missing := 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case verifyFailed:
+			failed++
+		case verifyMissing:
+			if cfg.ignoreMissing {
+				continue
+			}
+			missing++
+		}
+		verified++
+
+		if cfg.quiet {
+			continue
+		}
+		if encoder != nil {
+			if err := encoder.Encode(r); err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+				return exitError
+			}
+			continue
+		}
+		if r.Error != "" {
+			fmt.Fprintf(output, "%s: %s open or read\n", r.Filename, r.Status)
+		} else {
+			fmt.Fprintf(output, "%s: %s\n", r.Filename, r.Status)
+		}
+	}
+
+	if !cfg.quiet {
+		if malformed > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %d entry(s) improperly formatted\n", malformed)
+		}
+		if missing > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %d listed file(s) could not be found\n", missing)
+		}
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %d computed checksum(s) did NOT match\n", failed)
+		}
+		if verified == 0 && cfg.ignoreMissing {
+			fmt.Fprintf(os.Stderr, "%s: no file was verified\n", cfg.hashesFile)
+		}
+	}
+
+	if failed > 0 || missing > 0 {
+		return 1
+	}
+	if malformed > 0 && cfg.strict {
+		return 1
+	}
+	if verified == 0 && cfg.ignoreMissing {
+		return 1
+	}
+	return 0
+}
+
+// validEntry reports whether entry names a file and holds a SHA256 hash.
+func validEntry(entry AuditEntry) bool {
+	return entry.Filename != "" && isSHA256(entry.Hash)
+}
+
+// verifyEntries hashes the file of every entry using the given number of
+// workers and returns the results sorted by filename.
+func verifyEntries(auditMap map[string]AuditEntry, workers int) []verifyResult {
+	names := slices.Sorted(maps.Keys(auditMap))
+	results := make([]verifyResult, len(names))
+
+	jobs := make(chan int, len(names))
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = verifyEntry(auditMap[names[i]])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func verifyEntry(entry AuditEntry) verifyResult {
+	result := verifyResult{Filename: entry.Filename, Expected: entry.Hash}
+
+	hash, err := hashFile(entry.Filename)
+	switch {
+	case os.IsNotExist(err):
+		result.Status = verifyMissing
+	case err != nil:
+		result.Status = verifyFailed
+		result.Error = err.Error()
+	case hash != entry.Hash:
+		result.Status = verifyFailed
+		result.Actual = hash
+	default:
+		result.Status = verifyOK
+		result.Actual = hash
+	}
+	return result
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupVerify creates ok.txt and bad.txt and a hashes file with entries for
+// both plus a missing file, and returns the hashes file and the three paths.
+func setupVerify(t *testing.T) (hashesFile, ok, bad, missing string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	ok = filepath.Join(tempDir, "ok.txt")
+	bad = filepath.Join(tempDir, "bad.txt")
+	missing = filepath.Join(tempDir, "missing.txt")
+	for _, f := range []string{ok, bad} {
+		if err := os.WriteFile(f, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := hashFile(ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashesFile = filepath.Join(tempDir, "hashes.jsonl")
+	entries := map[string]AuditEntry{
+		ok:      {Filename: ok, Hash: hash},
+		bad:     {Filename: bad, Hash: strings.Repeat("0", 64)},
+		missing: {Filename: missing, Hash: hash},
+	}
+	if err := writeAuditFile(hashesFile, entries); err != nil {
+		t.Fatal(err)
+	}
+	return hashesFile, ok, bad, missing
+}
+
+func TestRunVerify(t *testing.T) {
+	hashesFile, ok, bad, missing := setupVerify(t)
+
+	var buf bytes.Buffer
+	cfg := Config{hashesFile: hashesFile, workers: 2, format: formatText}
+	exitCode := runVerify(cfg, &buf)
+
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", exitCode)
+	}
+	expected := bad + ": FAILED\n" + missing + ": MISSING\n" + ok + ": OK\n"
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestRunVerifyJSONL(t *testing.T) {
+	hashesFile, _, bad, _ := setupVerify(t)
+
+	var buf bytes.Buffer
+	cfg := Config{hashesFile: hashesFile, workers: 2, format: formatJSONL}
+	runVerify(cfg, &buf)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 JSONL records, got %d", len(lines))
+	}
+
+	var first verifyResult
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if first.Filename != bad || first.Status != verifyFailed {
+		t.Errorf("expected FAILED record for %s, got %+v", bad, first)
+	}
+	if first.Expected == first.Actual || first.Actual == "" {
+		t.Errorf("expected differing expected and actual hashes, got %+v", first)
+	}
+}
+
+func TestRunVerifyIgnoreMissing(t *testing.T) {
+	hashesFile, ok, bad, _ := setupVerify(t)
+
+	// Fix the mismatching entry so only the missing file is left
+	auditMap := loadAuditFile(hashesFile)
+	hash, err := hashFile(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditMap[bad] = AuditEntry{Filename: bad, Hash: hash}
+	if err := writeAuditFile(hashesFile, auditMap); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	cfg := Config{hashesFile: hashesFile, workers: 1, format: formatText, ignoreMissing: true}
+	if exitCode := runVerify(cfg, &buf); exitCode != 0 {
+		t.Errorf("expected exit code 0, got %d", exitCode)
+	}
+	expected := bad + ": OK\n" + ok + ": OK\n"
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestRunVerifyIgnoreMissingNothingVerified(t *testing.T) {
+	hashesFile := filepath.Join(t.TempDir(), "hashes.jsonl")
+	entries := map[string]AuditEntry{"gone.txt": {Filename: "gone.txt", Hash: strings.Repeat("a", 64)}}
+	if err := writeAuditFile(hashesFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{hashesFile: hashesFile, workers: 1, format: formatText, ignoreMissing: true, quiet: true}
+	if exitCode := runVerify(cfg, &bytes.Buffer{}); exitCode != 1 {
+		t.Errorf("expected exit code 1 when no file was verified, got %d", exitCode)
+	}
+}
+
+func TestRunVerifyStrict(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashesFile := filepath.Join(tempDir, "hashes.jsonl")
+	entries := map[string]AuditEntry{
+		file:        {Filename: file, Hash: hash},
+		"short.txt": {Filename: "short.txt", Hash: "abc"},
+	}
+	if err := writeAuditFile(hashesFile, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	cfg := Config{hashesFile: hashesFile, workers: 1, format: formatText, quiet: true}
+	if exitCode := runVerify(cfg, &buf); exitCode != 0 {
+		t.Errorf("expected malformed entry to be ignored without --strict, got exit code %d", exitCode)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output in quiet mode, got %q", buf.String())
+	}
+
+	cfg.strict = true
+	if exitCode := runVerify(cfg, &buf); exitCode != 1 {
+		t.Errorf("expected exit code 1 with --strict, got %d", exitCode)
+	}
+}
+
+func TestValidEntry(t *testing.T) {
+	valid := strings.Repeat("ab", 32)
+	tests := []struct {
+		entry    AuditEntry
+		expected bool
+	}{
+		{AuditEntry{Filename: "a", Hash: valid}, true},
+		{AuditEntry{Filename: "", Hash: valid}, false},
+		{AuditEntry{Filename: "a", Hash: "abc"}, false},
+		{AuditEntry{Filename: "a", Hash: strings.Repeat("zz", 32)}, false},
+	}
+
+	for _, tt := range tests {
+		if got := validEntry(tt.entry); got != tt.expected {
+			t.Errorf("validEntry(%+v) = %v, expected %v", tt.entry, got, tt.expected)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 )
 
 var errMutex sync.Mutex
@@ -16,25 +17,61 @@ func logError(format string, args ...any) {
 	errMutex.Unlock()
 }
 
-func processFiles(files []string, cfg Config, auditMap map[string]string, output io.Writer) {
-	jobs := make(chan string, len(files))
+// processFiles runs processFile on every file using cfg.workers workers,
+// writes the results to output and returns a summary of the run.
+func processFiles(files []string, cfg Config, auditMap map[string]AuditEntry, output io.Writer) *Summary {
+	jobs := make(chan string)
 	results := make(chan *Result, len(files))
 
-	// Initialize progress reporter
+	// Initialize progress reporter and run summary
 	progress := NewProgressReporter(len(files), cfg.showProgress, cfg.quiet)
+	summary := &Summary{started: time.Now()}
+	cfg.summary = summary
+
+	// Files without an entry may be renamed versions of deleted files
+	if auditMap != nil && cfg.renames == nil {
+		cfg.renames = newRenameIndex(auditMap)
+	}
+	if cfg.trustContent {
+		cfg.trusted = newTrustIndex(cfg, auditMap)
+	}
+
+	// In journal mode results are appended to the hashes file as they arrive
+	if cfg.journal && cfg.update && cfg.hashesFile != "" {
+		j, err := openJournal(cfg.hashesFile, auditMap)
+		if err != nil {
+			logError("Error opening hashes file journal, falling back to .new file: %v\n", err)
+		} else {
+			cfg.journalFile = j
+		}
+	}
+
+	// With --fail-fast, the first outcome failing the run stops it
+	if cfg.failFast {
+		cfg.halt = newHalter(cfg.interrupt.context())
+		defer cfg.halt.halt()
+	}
 
 	// Start workers
 	var wg sync.WaitGroup
 	for range cfg.workers {
 		wg.Add(1)
-		go worker(&wg, jobs, results, cfg, auditMap, progress)
+		go worker(&wg, jobs, results, cfg, auditMap, progress, summary)
 	}
 
-	// Send jobs
-	for _, file := range files {
-		jobs <- file
-	}
-	close(jobs)
+	// Send jobs as the workers take them, until the run is stopped
+	go func() {
+		defer close(jobs)
+		for _, file := range files {
+			select {
+			case jobs <- file:
+			case <-cfg.interrupt.done():
+				return
+			case <-cfg.halt.done():
+				return
+			}
+		}
+	}()
 
 	// Start result writer
 	done := make(chan bool)
@@ -49,18 +86,30 @@ func processFiles(files []string, cfg Config, auditMap map[string]string, output
 
 	// Show final progress
 	progress.Finish()
+
+	return summary
 }
 
-func worker(wg *sync.WaitGroup, jobs <-chan string, results chan<- *Result, cfg Config, auditMap map[string]string, progress *ProgressReporter) {
+func worker(wg *sync.WaitGroup, jobs <-chan string, results chan<- *Result, cfg Config, auditMap map[string]AuditEntry, progress *ProgressReporter, summary *Summary) {
 	defer wg.Done()
 
 	for filename := range jobs {
+		// After an interrupt or --fail-fast stop the remaining files are
+		// left for the next run
+		if cfg.interrupt.interrupted() || cfg.halt.halted() {
+			continue
+		}
+
 		result := processFile(filename, cfg, auditMap)
 
 		// Update progress
 		changed := result != nil && result.Changed
 		errored := result == nil
 		progress.Update(changed, errored)
+		summary.add(result)
+		if cfg.failFast && summary.exitCode(cfg.failOn) != exitOK {
+			cfg.halt.halt()
+		}
 
 		if result != nil {
 			results <- result
@@ -69,12 +118,18 @@ func worker(wg *sync.WaitGroup, jobs <-chan string, results chan<- *Result, cfg
 }
 
 func writeResults(results <-chan *Result, output io.Writer, done chan<- bool, cfg Config) {
-	encoder := json.NewEncoder(output)
+	encoder := newResultEncoder(cfg, output)
+
+	// The --output file gets JSONL results whatever the format on stdout
+	var resultsEncoder *json.Encoder
+	if cfg.results != nil {
+		resultsEncoder = json.NewEncoder(cfg.results)
+	}
 
 	// Open .new file for successful hashes if update mode is enabled
 	var newFile *os.File
 	var newEncoder *json.Encoder
-	if cfg.update && cfg.hashesFile != "" {
+	if cfg.update && cfg.hashesFile != "" && cfg.journalFile == nil {
 		var err error
 		newFile, err = os.Create(cfg.hashesFile + ".new")
 		if err != nil {
@@ -86,18 +141,56 @@ func writeResults(results <-chan *Result, output io.Writer, done chan<- bool, cf
 		}
 	}
 
+	// Reports are written once all results are in
+	reports := newReports(cfg)
+
 	for result := range results {
+		for _, r := range reports {
+			r.add(result)
+		}
+
 		// Write to main output
-		if err := encoder.Encode(result); err != nil {
+		if err := encoder.encode(result); err != nil {
 			if !cfg.quiet {
 				logError("Error encoding result: %v\n", err)
 			}
 		}
 
-		// Write successful results to .new file if update mode is enabled
-		if newEncoder != nil && result.ExitCode == 0 {
-			entry := AuditEntry{Filename: result.Filename, Hash: result.Hash}
-			if err := newEncoder.Encode(entry); err != nil {
+		if resultsEncoder != nil {
+			if err := resultsEncoder.Encode(jsonRecord(result)); err != nil && !cfg.quiet {
+				logError("Error writing to output file: %v\n", err)
+			}
+		}
+
+		if cfg.historyRun != nil {
+			if err := cfg.historyRun.add(result); err != nil && !cfg.quiet {
+				logError("Error writing run history: %v\n", err)
+			}
+		}
+
+		// Write results to the journal or .new file if update mode is enabled
+		if (cfg.journalFile != nil || newEncoder != nil) && cacheable(result) {
+			entry := AuditEntry{
+				Filename:    result.Filename,
+				Hash:        result.Hash,
+				ExitCode:    result.ExitCode,
+				Rule:        result.Rule,
+				Check:       result.check,
+				CheckedAt:   result.checkedAt,
+				RenamedFrom: result.RenamedFrom,
+			}
+			if result.output != "" {
+				blob, err := writeBlob(blobDir(cfg.hashesFile), []byte(result.output))
+				if err != nil && !cfg.quiet {
+					logError("Error storing output of %s: %v\n", result.Filename, err)
+				}
+				entry.OutputBlob = blob
+			}
+			if cfg.journalFile != nil {
+				if err := cfg.journalFile.add(entry); err != nil && !cfg.quiet {
+					logError("Error writing to hashes file journal: %v\n", err)
+				}
+			} else if err := newEncoder.Encode(entry); err != nil {
 				if !cfg.quiet {
 					logError("Error writing to .new file: %v\n", err)
 				}
@@ -105,9 +198,20 @@ func writeResults(results <-chan *Result, output io.Writer, done chan<- bool, cf
 		}
 	}
 
+	if err := encoder.close(); err != nil {
+		if !cfg.quiet {
+			logError("Error writing output: %v\n", err)
+		}
+	}
 	if newFile != nil {
 		newFile.Close()
 	}
 
+	for _, r := range reports {
+		if err := r.write(); err != nil {
+			logError("Error writing report: %v\n", err)
+		}
+	}
+
 	done <- true
 }